@@ -4,28 +4,41 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/netip"
 	"os"
+	"os/signal"
 	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/salex-org/ikea-dirigera-client/pkg/client"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"github.com/zalando/go-keyring"
 )
 
 // authorizeCmd represents the authorize command
 var authorizeCmd = &cobra.Command{
-	Use: "authorize <ip-address>",
+	Use: "authorize [<ip-address>|<hostname>]",
 	Args: func(cmd *cobra.Command, args []string) error {
-		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
 			return err
 		}
-		if _, err := netip.ParseAddr(args[0]); err != nil {
-			return fmt.Errorf("invalid IP address: %w", err)
+		serialNumber, _ := cmd.Flags().GetString("serial")
+		if len(args) > 0 && serialNumber != "" {
+			return fmt.Errorf("address and --serial can not be used together")
+		}
+		if len(args) > 0 {
+			if _, err := netip.ParseAddr(args[0]); err != nil && !isValidHostname(args[0]) {
+				return fmt.Errorf("invalid IP address or hostname: %s", args[0])
+			}
 		}
 		return nil
 	},
@@ -33,29 +46,84 @@ var authorizeCmd = &cobra.Command{
 	Short:   "Authorizes a new user on a IKEA dirigera hub",
 	Long: `Creates a new user on a IKEA dirigera hub and creates an access token for the API. Optionally creates a new
 context in the CLI for the hub to be used by the user. During the authorization the button on the backside of the hub
-needs to be pressed!
+needs to be pressed! Without an address the hubs in the local network are searched using mDNS and the hub to
+authorize can be selected interactively or by its serial number.
 
 Examples:
 
+ikea authorize
+
+ikea authorize --serial 1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d
+
 ikea authorize 192.168.1.1
 
+ikea authorize gw2-1a2b3c4d5e6f.local
+
 ikea authorize 192.168.1.1 --port 1234
 
 ikea authorize 192.168.1.1 --context my-context
 
-ikea authorize 192.168.1.1 --no-context`,
+ikea authorize 192.168.1.1 --user-name home-automation
+
+ikea authorize 192.168.1.1 --no-context
+
+ikea authorize 192.168.1.1 --json --timeout 5m > credentials.json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ip := args[0]
 		port, _ := cmd.Flags().GetInt("port")
 		contextName, _ := cmd.Flags().GetString("context")
 		skipContext, _ := cmd.Flags().GetBool("no-context")
+		userName, _ := cmd.Flags().GetString("user-name")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if userName == "" {
+			userName = generateClientName()
+		}
 
-		context, err := authorize(ip, port)
+		// Messages go to stderr in JSON mode to keep stdout parseable for provisioning scripts
+		out := io.Writer(os.Stdout)
+		if jsonOutput {
+			out = os.Stderr
+		}
+
+		var address, serialNumber string
+		if len(args) > 0 {
+			address = args[0]
+		} else {
+			hub, err := discoverHub(cmd, out)
+			if err != nil {
+				return err
+			}
+			address, serialNumber = hub.Address, hub.SerialNumber
+			if !cmd.Flags().Changed("port") && hub.Port != 0 {
+				port = hub.Port
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		context, err := authorize(ctx, address, port, userName, timeout, out)
 		if err != nil {
 			return fmt.Errorf("authorize failed: %v", err)
 		}
-		fmt.Printf("Created new user on IKEA DIRIGERA Hub %s\n", context.Address)
+		if serialNumber != "" {
+			context.SerialNumber = serialNumber
+		} else {
+			context.SerialNumber = findSerialNumber(address, out)
+		}
+		_, _ = fmt.Fprintf(out, "Created new user on IKEA DIRIGERA Hub %s\n", context.Address)
 
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Address      string `json:"address"`
+				Port         int    `json:"port"`
+				AccessToken  string `json:"access_token"`
+				Fingerprint  string `json:"fingerprint"`
+				SerialNumber string `json:"serial_number,omitempty"`
+				UserName     string `json:"user_name"`
+			}{context.Address, context.Port, context.AccessToken, context.Fingerprint, context.SerialNumber, userName})
+		}
 		if skipContext {
 			fmt.Printf("Access Token: %s\nTLS Fingerprint:%s\n\n", context.AccessToken, context.Fingerprint)
 			return nil
@@ -67,28 +135,28 @@ ikea authorize 192.168.1.1 --no-context`,
 			}
 		}
 
+		storeName, _ := cmd.Flags().GetString("credential-store")
+		store, err := getCredentialStore(storeName)
+		if err != nil {
+			return err
+		}
+		if storeName != credentialStoreKeyring {
+			context.CredentialStore = storeName
+		}
 		appConfig.Contexts[contextName] = context
-		viper.Set("contexts", appConfig.Contexts)
 
 		if appConfig.CurrentContext == "" {
 			appConfig.CurrentContext = contextName
-			viper.Set("current_context", appConfig.CurrentContext)
 		}
 
-		err = viper.WriteConfig()
-		if err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				if err := viper.SafeWriteConfig(); err != nil {
-					return fmt.Errorf("error writing config: %w", err)
-				}
-			} else {
-				return fmt.Errorf("error writing config: %w", err)
-			}
+		if err := writeConfig(); err != nil {
+			return err
 		}
 
-		err = keyring.Set(appName, contextName, context.AccessToken)
-		if err != nil {
-			return fmt.Errorf("error writing token to keyring: %w", err)
+		if storeName == credentialStoreEnv {
+			fmt.Printf("Set the environment variable %s to use the context:\n%s=%s\n", tokenVariableName(contextName), tokenVariableName(contextName), context.AccessToken)
+		} else if err := store.Set(contextName, context.AccessToken); err != nil {
+			return fmt.Errorf("error writing token to %s: %w", store.Describe(), err)
 		}
 		fmt.Printf("Created new context with name %s\n", contextName)
 
@@ -101,22 +169,117 @@ func init() {
 	authorizeCmd.Flags().IntP("port", "p", 8443, "The port used to connect to the IKEA DIRIGERA Hub")
 	authorizeCmd.Flags().StringP("context", "c", "", "Specifies the name of the context to create (default name of IKEA DIRIGERA Hub)")
 	authorizeCmd.Flags().BoolP("no-context", "n", false, "Don't create a context, just create the user")
+	authorizeCmd.Flags().StringP("user-name", "u", "", "Specifies the name of the user to create (default <user>@<host>)")
+	authorizeCmd.Flags().StringP("serial", "s", "", "Specifies the serial number of the hub to search for instead of an address")
+	authorizeCmd.Flags().DurationP("timeout", "t", time.Minute, "Defines how long to wait for the button on the hub to be pressed")
+	authorizeCmd.Flags().String("credential-store", credentialStoreKeyring, "Defines where to store the access token (keyring, file, encrypted-file or env)")
+	authorizeCmd.Flags().Bool("json", false, "Print the credentials as JSON instead of creating a context (messages go to stderr)")
+}
+
+// discoverHub scans for hubs and returns the hub with the serial number specified by flag or the hub selected
+// interactively by the user.
+func discoverHub(cmd *cobra.Command, out io.Writer) (*client.DirigeraHub, error) {
+	serialNumber, _ := cmd.Flags().GetString("serial")
+	_, _ = fmt.Fprintf(out, "Searching for IKEA DIRIGERA Hubs...\n")
+	hubs, err := client.Scan()
+	if err != nil {
+		return nil, fmt.Errorf("could not scan for hubs: %w", err)
+	}
+	if serialNumber != "" {
+		for _, hub := range hubs {
+			if strings.EqualFold(hub.SerialNumber, serialNumber) {
+				return &hub, nil
+			}
+		}
+		return nil, fmt.Errorf("no hub found with serial number %s", serialNumber)
+	}
+	if len(hubs) == 0 {
+		return nil, fmt.Errorf("no hubs found, please specify the address of the hub")
+	}
+
+	return selectHub(hubs, out)
+}
+
+func selectHub(hubs []client.DirigeraHub, out io.Writer) (*client.DirigeraHub, error) {
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+	t.AppendHeader(table.Row{"Hostname", "IP", "Port", "Serial Number", "Firmware Version"})
+	for _, hub := range hubs {
+		t.AppendRow(table.Row{
+			hub.HostName, hub.Address, hub.Port, hub.SerialNumber, hub.FirmwareVersion,
+		})
+	}
+	t.SetStyle(table.StyleDefault)
+	t.SetAutoIndex(true)
+	_, _ = fmt.Fprintf(out, "found %d hubs:\n", len(hubs))
+	t.Render()
+
+	_, _ = fmt.Fprintf(out, "Select the hub to authorize [1-%d]: ", len(hubs))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		_, _ = fmt.Fprintln(out)
+		return nil, fmt.Errorf("no hub selected")
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" && len(hubs) == 1 {
+		return &hubs[0], nil
+	}
+	index, err := strconv.Atoi(answer)
+	if err != nil || index < 1 || index > len(hubs) {
+		return nil, fmt.Errorf("invalid selection: %s", answer)
+	}
+
+	return &hubs[index-1], nil
 }
 
-func authorize(ip string, port int) (*Context, error) {
-	clientName := generateClientName()
-	fmt.Printf("Adding new user %s to IKEA DIRIGERA Hub at %s:%d\n", clientName, ip, port)
-	auth, err := client.Authorize(ip, port, clientName, func() {
-		fmt.Printf("Please press the button on the backside of the Hub within 1 minute...")
-	}, func() {
-		fmt.Printf(".")
+// isValidHostname checks the syntax of a hostname according to RFC 1123 without resolving it.
+func isValidHostname(hostname string) bool {
+	hostname = strings.TrimSuffix(hostname, ".")
+	if hostname == "" || len(hostname) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, character := range label {
+			isLetter := (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z')
+			isDigit := character >= '0' && character <= '9'
+			if !isLetter && !isDigit && character != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func authorize(ctx context.Context, ip string, port int, clientName string, timeout time.Duration, out io.Writer) (*Context, error) {
+	_, _ = fmt.Fprintf(out, "Adding new user %s to IKEA DIRIGERA Hub at %s:%d\n", clientName, ip, port)
+	var lastStatus client.AuthorizeStatus
+	auth, err := client.AuthorizeWithOptions(ctx, ip, port, client.AuthorizeOptions{
+		ClientName: clientName,
+		Timeout:    timeout,
+		Logger:     logger,
+		Progress: func(progress client.AuthorizeProgress) {
+			switch {
+			case progress.Attempt == 0:
+				_, _ = fmt.Fprintf(out, "Please press the button on the backside of the Hub within %s...", timeout)
+			case progress.Status == client.AuthorizeCompleted:
+			case progress.Status != lastStatus && progress.Err != nil && progress.Status != client.AuthorizeWaitingForButton:
+				_, _ = fmt.Fprintf(out, "\n%s: %v\n", progress.Status, progress.Err)
+			default:
+				_, _ = fmt.Fprintf(out, ".")
+			}
+			lastStatus = progress.Status
+		},
 	})
 	if err != nil {
-		fmt.Printf("failed: %v\n", err)
+		_, _ = fmt.Fprintf(out, "failed: %v\n", err)
 
 		return nil, fmt.Errorf("error authorizing new client: %w", err)
 	}
-	fmt.Printf("success\n")
+	_, _ = fmt.Fprintf(out, "success\n")
 
 	return &Context{
 		AccessToken: auth.AccessToken,
@@ -126,6 +289,24 @@ func authorize(ip string, port int) (*Context, error) {
 	}, nil
 }
 
+// findSerialNumber looks up the serial number of the hub with the specified address or hostname using mDNS.
+// The serial number is used to find the hub again after an address change. An empty string is returned if the hub
+// was not found.
+func findSerialNumber(address string, out io.Writer) string {
+	hubs, err := client.Scan()
+	if err != nil {
+		return ""
+	}
+	for _, hub := range hubs {
+		if hub.Address == address || strings.EqualFold(strings.TrimSuffix(hub.HostName, "."), strings.TrimSuffix(address, ".")) {
+			return hub.SerialNumber
+		}
+	}
+	_, _ = fmt.Fprintf(out, "warning: hub not found via mDNS, the context will not follow address changes of the hub\n")
+
+	return ""
+}
+
 func generateClientName() string {
 	hostname, err := os.Hostname()
 	hostname = strings.SplitN(hostname, ".", 2)[0]
@@ -141,20 +322,10 @@ func generateClientName() string {
 }
 
 func getHubName(context *Context) (string, error) {
-	dirigeraClient := client.Connect(context.Address, context.Port, &client.Authorization{
-		AccessToken:    context.AccessToken,
-		TLSFingerprint: context.Fingerprint,
-	})
-	hub, err := dirigeraClient.GetHubStatus()
+	hub, err := getDirigeraClient(context).GetHub()
 	if err != nil {
 		return "", err
 	}
-	customNameValue, hasCustomName := hub.Attributes["customName"]
-	if hasCustomName {
-		if customName, isString := customNameValue.(string); isString {
-			return customName, nil
-		}
-	}
 
-	return "", nil
+	return hub.Name, nil
 }
@@ -0,0 +1,130 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/automation"
+	"github.com/spf13/cobra"
+)
+
+// automateCmd represents the automate command
+var automateCmd = &cobra.Command{
+	Use:   "automate",
+	Short: "Run automation rules on the events of the IKEA DIRIGERA Hub",
+	Long: `Runs the rules defined in a file until stopped by Ctrl-C. A rule runs its actions when one of its triggers
+fires and all of its conditions are met.
+
+Triggers:   attribute (changed by an event, optionally to a value or crossing above/below), event (type),
+            at (HH:MM, sunrise or sunset with an offset like sunset-30m), cron (five fields) and unreachable
+            (for a duration). Attribute, event and unreachable triggers are restricted by devices and rooms.
+Conditions: device (attribute is a value or above/below), time window (after, before, weekdays) and room
+            (occupied by a motion or occupancy sensor, optionally within a duration).
+Actions:    set (attributes of devices and rooms), scene, webhook, delay and notify.
+
+Sunrise and sunset are computed from the coordinates of the hub, times are in the timezone of the hub. The mode of
+a rule defines what happens if it is triggered while running: single ignores the trigger, restart starts again
+and parallel runs once more. Webhook bodies and notify messages are Go templates with the fields Rule, Trigger,
+Time, DeviceID, Device, DeviceType, Room, Attributes (changed attributes) and State (all attributes).
+
+With --dry-run the rules are evaluated but devices, scenes, webhooks and notifications are not touched. Every run
+of a rule is logged with the name of the rule, use --log-level info or --log-dir to see them.
+
+Example rules.yaml:
+
+notify:
+  url: https://ntfy.sh/${NTFY_TOPIC}
+  format: ntfy
+rules:
+  - name: hallway-light
+    mode: restart
+    triggers:
+      - attribute: isDetected
+        to: true
+        devices: [Hallway sensor]
+    conditions:
+      - after: sunset-30m
+        before: sunrise
+    actions:
+      - set: {isOn: true, lightLevel: 40}
+        devices: [Hallway light]
+      - delay: 5m
+      - set: {isOn: false}
+        devices: [Hallway light]
+  - name: evening
+    triggers:
+      - at: sunset
+    conditions:
+      - room: Living room
+        within: 30m
+    actions:
+      - scene: Evening
+  - name: too-warm
+    triggers:
+      - attribute: currentTemperature
+        above: 26
+        rooms: [Bedroom]
+    actions:
+      - notify: '{{ .Device }} in {{ .Room }} measures {{ .State.currentTemperature }}°C'
+  - name: fridge-offline
+    triggers:
+      - unreachable: true
+        for: 10m
+        devices: [Fridge outlet]
+    actions:
+      - webhook: https://example.com/hooks/fridge
+        body: '{"device":{{ json .Device }}}'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		logDirectory, _ := cmd.Flags().GetString("log-dir")
+		config, err := automation.LoadConfig(file)
+		if err != nil {
+			return err
+		}
+		// A dry run is useless without seeing what the rules would do
+		if dryRun && !cmd.Flags().Changed("log-level") {
+			_ = cmd.Flags().Set("log-level", "info")
+			if err := initLogger(cmd); err != nil {
+				return err
+			}
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		engine := automation.New(getDirigeraClient(usedContext), config, automation.Options{
+			DryRun:       dryRun,
+			LogDirectory: logDirectory,
+			Logger:       logger,
+		})
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		mode := ""
+		if dryRun {
+			mode = " (dry run)"
+		}
+		fmt.Printf("Running %d rules on context %s%s...\n", len(config.Rules), usedContextName, mode)
+		if err := engine.Run(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Automation stopped")
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(automateCmd)
+	automateCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	automateCmd.Flags().StringP("file", "f", "rules.yaml", "Defines the YAML file with the rules")
+	automateCmd.Flags().Bool("dry-run", false, "Only log the actions instead of changing devices or sending requests")
+	automateCmd.Flags().String("log-dir", "", "Defines a directory for a log file of every rule")
+}
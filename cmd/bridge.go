@@ -0,0 +1,175 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/bridge"
+	"github.com/spf13/cobra"
+)
+
+// bridgeCmd represents the bridge command
+var bridgeCmd = &cobra.Command{
+	Use:   "bridge",
+	Short: "Connect the IKEA DIRIGERA Hub with other systems",
+}
+
+// bridgeMQTTCmd represents the bridge mqtt command
+var bridgeMQTTCmd = &cobra.Command{
+	Use:   "mqtt",
+	Short: "Publish the devices of the IKEA DIRIGERA Hub to an MQTT broker",
+	Long: `Publishes the state of every device as retained JSON message to a state topic and writes the attributes of JSON
+objects received on the set topic of a device back to the device, until stopped by Ctrl-C. The optional field
+transitionTime in a set message defines the duration of the change in milliseconds.
+
+Topics are Go templates with the fields Hub, Room, Device, DeviceID and Type. The availability topic is set to
+online while the bridge is running and to offline when it stops, also by the last will if the bridge terminates
+unexpectedly. The password can be set with the environment variable IKEA_MQTT_PASSWORD instead of --password.
+
+With --homeassistant the devices are published as Home Assistant entities using MQTT discovery: lights, outlets
+as switches, blinds as covers, air purifiers as fans and sensors for the measured values and batteries. Entities
+are updated when devices are added, renamed, moved or removed.
+
+Examples:
+
+ikea bridge mqtt --broker tcp://localhost:1883
+
+ikea bridge mqtt --broker ssl://broker:8883 --ca-file ca.pem --username dirigera
+
+ikea bridge mqtt --homeassistant
+
+mosquitto_pub -t dirigera/my_hub/kitchen/ceiling/set -m '{"isOn":true,"lightLevel":50,"transitionTime":1000}'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options, err := getMQTTOptions(cmd)
+		if err != nil {
+			return err
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+		mqttBridge, err := bridge.NewMQTTBridge(dirigeraClient, options)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("Bridging context %s to %s...\n", usedContextName, options.Broker)
+		if err := mqttBridge.Run(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Bridge stopped")
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bridgeCmd)
+	bridgeCmd.PersistentFlags().StringP("context", "c", "", "Defines the context to use")
+
+	bridgeCmd.AddCommand(bridgeMQTTCmd)
+	addMQTTFlags(bridgeMQTTCmd)
+	bridgeMQTTCmd.Flags().String("state-topic", bridge.DefaultStateTopic, "Defines the template of the state topics")
+	bridgeMQTTCmd.Flags().String("set-topic", bridge.DefaultSetTopic, "Defines the template of the set topics")
+	bridgeMQTTCmd.Flags().String("availability-topic", bridge.DefaultAvailabilityTopic, "Defines the template of the availability topic")
+	bridgeMQTTCmd.Flags().String("hub-name", "", "Defines the name of the hub used in topics (default name of the hub)")
+	bridgeMQTTCmd.Flags().Bool("homeassistant", false, "Publish Home Assistant discovery configs for the devices")
+	bridgeMQTTCmd.Flags().String("discovery-prefix", bridge.DefaultDiscoveryPrefix, "Defines the prefix of the Home Assistant discovery topics")
+}
+
+// addMQTTFlags adds the flags for the connection to the MQTT broker.
+func addMQTTFlags(cmd *cobra.Command) {
+	cmd.Flags().String("broker", "tcp://localhost:1883", "Defines the URL of the MQTT broker (tcp, ssl, ws or wss)")
+	cmd.Flags().String("client-id", "", "Defines the client ID used at the broker (default "+appName+"-<hostname>)")
+	cmd.Flags().String("username", "", "Defines the username for the broker")
+	cmd.Flags().String("password", "", "Defines the password for the broker (default $IKEA_MQTT_PASSWORD)")
+	cmd.Flags().Uint8("qos", 1, "Defines the QoS of published messages and subscriptions (0, 1 or 2)")
+	cmd.Flags().String("ca-file", "", "Defines a PEM file with the CA certificates to verify the broker")
+	cmd.Flags().String("cert-file", "", "Defines a PEM file with the client certificate for the broker")
+	cmd.Flags().String("key-file", "", "Defines a PEM file with the key of the client certificate")
+	cmd.Flags().Bool("insecure", false, "Skip the verification of the broker certificate")
+}
+
+func getMQTTOptions(cmd *cobra.Command) (bridge.MQTTOptions, error) {
+	options := bridge.MQTTOptions{
+		Logger: logger,
+	}
+	options.Broker, _ = cmd.Flags().GetString("broker")
+	options.ClientID, _ = cmd.Flags().GetString("client-id")
+	options.Username, _ = cmd.Flags().GetString("username")
+	options.Password, _ = cmd.Flags().GetString("password")
+	options.QoS, _ = cmd.Flags().GetUint8("qos")
+	options.HubName, _ = cmd.Flags().GetString("hub-name")
+	options.StateTopic, _ = cmd.Flags().GetString("state-topic")
+	options.SetTopic, _ = cmd.Flags().GetString("set-topic")
+	options.AvailabilityTopic, _ = cmd.Flags().GetString("availability-topic")
+	options.HomeAssistantDiscovery, _ = cmd.Flags().GetBool("homeassistant")
+	options.DiscoveryPrefix, _ = cmd.Flags().GetString("discovery-prefix")
+
+	broker, err := url.Parse(options.Broker)
+	if err != nil || broker.Host == "" {
+		return options, fmt.Errorf("invalid broker URL %s: must be like tcp://host:1883", options.Broker)
+	}
+	if options.ClientID == "" {
+		hostname, _ := os.Hostname()
+		options.ClientID = appName + "-" + hostname
+	}
+	if options.Password == "" {
+		options.Password = os.Getenv("IKEA_MQTT_PASSWORD")
+	}
+	if options.QoS > 2 {
+		return options, fmt.Errorf("invalid QoS %d: must be 0, 1 or 2", options.QoS)
+	}
+	options.TLSConfig, err = getMQTTTLSConfig(cmd)
+
+	return options, err
+}
+
+// getMQTTTLSConfig returns the TLS config for the broker or nil if the default config of the system is sufficient.
+func getMQTTTLSConfig(cmd *cobra.Command) (*tls.Config, error) {
+	caFile, _ := cmd.Flags().GetString("ca-file")
+	certFile, _ := cmd.Flags().GetString("cert-file")
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	insecure, _ := cmd.Flags().GetBool("insecure")
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("--cert-file and --key-file must be specified together")
+	}
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,381 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// contextCmd represents the context command
+var contextCmd = &cobra.Command{
+	Use:     "context",
+	Aliases: []string{"ctx"},
+	Short:   "Maintain the contexts defined in the CLI config",
+}
+
+// contextShowCmd represents the context show command
+var contextShowCmd = &cobra.Command{
+	Use:   "show [<name>]",
+	Short: "Show the current or specified context",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		contextName := appConfig.CurrentContext
+		if len(args) > 0 {
+			contextName = args[0]
+		} else if envContextName != "" {
+			contextName = envContextName
+		}
+		var context *Context
+		if isEnvContext(contextName) {
+			if envContextError != nil {
+				return envContextError
+			}
+			context = envContext
+		} else {
+			found := false
+			context, found = appConfig.Contexts[contextName]
+			if !found {
+				return fmt.Errorf("unknown context: %s", contextName)
+			}
+			// A missing token is shown in the output instead of failing
+			_ = loadAccessToken(contextName, context)
+		}
+		verify, _ := cmd.Flags().GetBool("verify")
+		var verifyErr error
+		if verify {
+			verifyErr = verifyContext(context)
+		}
+		return printOutput(cmd, context, func(writer io.Writer) {
+			_, _ = fmt.Fprintf(writer, "name: %s\ncurrent: %t\n", contextName, contextName == appConfig.CurrentContext)
+			_, _ = fmt.Fprintf(writer, "address: %s\nport: %d\nTLS fingerprint: %s\n", context.Address, context.Port, context.Fingerprint)
+			for _, pinned := range context.PinnedFingerprints {
+				_, _ = fmt.Fprintf(writer, "pinned TLS fingerprint: %s\n", pinned)
+			}
+			_, _ = fmt.Fprintf(writer, "serial number: %s\n", context.SerialNumber)
+			_, _ = fmt.Fprintf(writer, "access token: %s\n", describeToken(context))
+			if verify {
+				if verifyErr != nil {
+					_, _ = fmt.Fprintf(writer, "verification: failed: %v\n", verifyErr)
+				} else {
+					_, _ = fmt.Fprintf(writer, "verification: ok\n")
+				}
+			}
+		})
+	},
+}
+
+// contextRenameCmd represents the context rename command
+var contextRenameCmd = &cobra.Command{
+	Use:   "rename <name> <new-name>",
+	Short: "Rename a context",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		contextName, newContextName := args[0], args[1]
+		context, err := lookupContext(contextName)
+		if err != nil {
+			return err
+		}
+		if _, exists := appConfig.Contexts[newContextName]; exists {
+			return fmt.Errorf("context %s already exists", newContextName)
+		}
+
+		store, err := getCredentialStore(context.CredentialStore)
+		if err != nil {
+			return err
+		}
+		if context.CredentialStore == credentialStoreEnv {
+			fmt.Printf("Rename the environment variable %s to %s\n", tokenVariableName(contextName), tokenVariableName(newContextName))
+		} else if err := store.Set(newContextName, context.AccessToken); err != nil {
+			return fmt.Errorf("error writing token to %s: %w", store.Describe(), err)
+		}
+		delete(appConfig.Contexts, contextName)
+		appConfig.Contexts[newContextName] = context
+		if appConfig.CurrentContext == contextName {
+			appConfig.CurrentContext = newContextName
+		}
+		if err := writeConfig(); err != nil {
+			_ = store.Delete(newContextName)
+			return err
+		}
+		if err := store.Delete(contextName); err != nil && !errors.Is(err, errCredentialNotFound) {
+			fmt.Printf("warning: could not remove token of context %s from %s: %v\n", contextName, store.Describe(), err)
+		}
+		fmt.Printf("Renamed context %s to %s\n", contextName, newContextName)
+
+		return nil
+	},
+}
+
+// contextCopyCmd represents the context copy command
+var contextCopyCmd = &cobra.Command{
+	Use:     "copy <name> <new-name>",
+	Aliases: []string{"cp"},
+	Short:   "Copy a context including its access token",
+	Long: `Copies a context including its access token. Both contexts use the same user in the IKEA DIRIGERA Hub, so
+deleting one of them with the user removes the access for the other one as well.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		contextName, newContextName := args[0], args[1]
+		context, err := lookupContext(contextName)
+		if err != nil {
+			return err
+		}
+		if _, exists := appConfig.Contexts[newContextName]; exists {
+			return fmt.Errorf("context %s already exists", newContextName)
+		}
+
+		newContext := *context
+		store, err := getCredentialStore(newContext.CredentialStore)
+		if err != nil {
+			return err
+		}
+		if newContext.CredentialStore == credentialStoreEnv {
+			fmt.Printf("Set the environment variable %s to use the context\n", tokenVariableName(newContextName))
+		} else if err := store.Set(newContextName, newContext.AccessToken); err != nil {
+			return fmt.Errorf("error writing token to %s: %w", store.Describe(), err)
+		}
+		appConfig.Contexts[newContextName] = &newContext
+		if err := writeConfig(); err != nil {
+			delete(appConfig.Contexts, newContextName)
+			_ = store.Delete(newContextName)
+			return err
+		}
+		fmt.Printf("Copied context %s to %s\n", contextName, newContextName)
+
+		return nil
+	},
+}
+
+// contextSetAddressCmd represents the context set-address command
+var contextSetAddressCmd = &cobra.Command{
+	Use:   "set-address <name> <ip-address>",
+	Short: "Change the address of the hub in a context",
+	Long: `Changes the address of the hub in a context, e.g. after the hub got a new address by DHCP. With --verify the
+connection to the new address is checked, including the stored TLS fingerprint, before the context is changed.
+
+Examples:
+
+ikea context set-address my-context 192.168.1.2 --verify`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if _, err := netip.ParseAddr(args[1]); err != nil {
+			return fmt.Errorf("invalid IP address: %w", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateContext(cmd, args[0], func(context *Context) {
+			context.Address = args[1]
+		})
+	},
+}
+
+// contextSetPortCmd represents the context set-port command
+var contextSetPortCmd = &cobra.Command{
+	Use:   "set-port <name> <port>",
+	Short: "Change the port of the hub in a context",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if port, err := strconv.Atoi(args[1]); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %s: must be a number between 1 and 65535", args[1])
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, _ := strconv.Atoi(args[1])
+		return updateContext(cmd, args[0], func(context *Context) {
+			context.Port = port
+		})
+	},
+}
+
+// contextMigrateCmd represents the context migrate command
+var contextMigrateCmd = &cobra.Command{
+	Use:   "migrate [<name>...]",
+	Short: "Move the access token of contexts to another credential store",
+	Long: `Moves the access token of the specified contexts or all contexts with --all to another credential store.
+
+Supported stores:
+
+keyring         the keyring of the operating system (default)
+file            a YAML file next to the config file readable only by the owner
+encrypted-file  a file next to the config file encrypted with a passphrase (read from IKEA_PASSPHRASE or prompted)
+env             the environment variable IKEA_TOKEN_<CONTEXT> (must be set manually)
+
+Examples:
+
+ikea context migrate my-context --to encrypted-file
+
+ikea context migrate --all --to file`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			return fmt.Errorf("specify either context names or --all")
+		}
+		target, _ := cmd.Flags().GetString("to")
+		if _, err := getCredentialStore(target); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, _ := cmd.Flags().GetString("to")
+		contextNames := args
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			for name := range appConfig.Contexts {
+				contextNames = append(contextNames, name)
+			}
+			slices.Sort(contextNames)
+		}
+		for _, contextName := range contextNames {
+			if err := migrateContext(contextName, target); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(contextCmd)
+	contextCmd.PersistentFlags().Bool("verify", false, "Connect to the hub and verify the stored TLS fingerprint")
+
+	contextCmd.AddCommand(contextShowCmd)
+	contextShowCmd.Flags().StringP("output", "o", "text", "Defines the format of the output (text or json)")
+
+	contextCmd.AddCommand(contextRenameCmd)
+	contextCmd.AddCommand(contextCopyCmd)
+	contextCmd.AddCommand(contextSetAddressCmd)
+	contextCmd.AddCommand(contextSetPortCmd)
+
+	contextCmd.AddCommand(contextMigrateCmd)
+	contextMigrateCmd.Flags().String("to", "", "Defines the credential store to move the access tokens to")
+	contextMigrateCmd.Flags().Bool("all", false, "Move the access tokens of all contexts")
+	_ = contextMigrateCmd.MarkFlagRequired("to")
+}
+
+// migrateContext copies the access token to the target store, switches the context to the target store and
+// removes the token from the previous store afterward.
+func migrateContext(contextName, target string) error {
+	context, err := lookupContext(contextName)
+	if err != nil {
+		return err
+	}
+	if target == credentialStoreKeyring {
+		target = ""
+	}
+	if context.CredentialStore == target {
+		fmt.Printf("Context %s already uses the credential store\n", contextName)
+		return nil
+	}
+	source, err := getCredentialStore(context.CredentialStore)
+	if err != nil {
+		return err
+	}
+	destination, err := getCredentialStore(target)
+	if err != nil {
+		return err
+	}
+
+	if target == credentialStoreEnv {
+		fmt.Printf("Set the environment variable %s to use the context:\n%s=%s\n", tokenVariableName(contextName), tokenVariableName(contextName), context.AccessToken)
+	} else if err := destination.Set(contextName, context.AccessToken); err != nil {
+		return fmt.Errorf("error writing token of context %s to %s: %w", contextName, destination.Describe(), err)
+	}
+	previousStore := context.CredentialStore
+	context.CredentialStore = target
+	if err := writeConfig(); err != nil {
+		context.CredentialStore = previousStore
+		_ = destination.Delete(contextName)
+		return err
+	}
+	if err := source.Delete(contextName); err != nil && !errors.Is(err, errCredentialNotFound) {
+		fmt.Printf("warning: could not remove token of context %s from %s: %v\n", contextName, source.Describe(), err)
+	}
+	fmt.Printf("Moved access token of context %s from %s to %s\n", contextName, source.Describe(), destination.Describe())
+
+	return nil
+}
+
+func lookupContext(contextName string) (*Context, error) {
+	if contextName == "" {
+		return nil, fmt.Errorf("context not set")
+	}
+	context, found := appConfig.Contexts[contextName]
+	if !found {
+		return nil, unknownContextError(contextName)
+	}
+	if err := loadAccessToken(contextName, context); err != nil {
+		return nil, err
+	}
+
+	return context, nil
+}
+
+// updateContext applies the change to a copy of the context, verifies the changed context if requested and
+// persists it afterward.
+func updateContext(cmd *cobra.Command, contextName string, change func(context *Context)) error {
+	context, err := lookupContext(contextName)
+	if err != nil {
+		return err
+	}
+	changedContext := *context
+	change(&changedContext)
+
+	if verify, _ := cmd.Flags().GetBool("verify"); verify {
+		if err := verifyContext(&changedContext); err != nil {
+			return fmt.Errorf("verification of context %s failed, context not changed: %w", contextName, err)
+		}
+		fmt.Printf("Verified connection to %s:%d\n", changedContext.Address, changedContext.Port)
+	}
+
+	appConfig.Contexts[contextName] = &changedContext
+	if err := writeConfig(); err != nil {
+		appConfig.Contexts[contextName] = context
+		return err
+	}
+	fmt.Printf("Updated context %s\n", contextName)
+
+	return nil
+}
+
+// verifyContext connects to the hub of the context. The connection fails if the certificate of the hub does not
+// match the stored TLS fingerprint or the access token is not accepted.
+func verifyContext(context *Context) error {
+	if context.Fingerprint == "" {
+		return fmt.Errorf("no TLS fingerprint stored in context")
+	}
+	// Connect without rediscovery to verify exactly the stored address
+	dirigeraClient := client.Connect(context.Address, context.Port, context.authorization())
+	if _, err := dirigeraClient.GetCurrentUser(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func describeToken(context *Context) string {
+	store, err := getCredentialStore(context.CredentialStore)
+	if err != nil {
+		return err.Error()
+	}
+	if context.AccessToken == "" {
+		return "not available in " + store.Describe()
+	}
+
+	return "available in " + store.Describe()
+}
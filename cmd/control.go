@@ -0,0 +1,516 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// Named colors accepted by the color command in addition to kelvin values and hex codes
+var colorNames = map[string]string{
+	"red":     "#ff0000",
+	"orange":  "#ff8000",
+	"yellow":  "#ffff00",
+	"green":   "#00ff00",
+	"cyan":    "#00ffff",
+	"blue":    "#0000ff",
+	"purple":  "#8000ff",
+	"magenta": "#ff00ff",
+	"pink":    "#ff80c0",
+}
+
+// Named color temperatures accepted by the color command
+var colorTemperatureNames = map[string]int{
+	"warm":    2700,
+	"neutral": 4000,
+	"cold":    5500,
+}
+
+// Tolerances used when comparing the requested with the reported value of an attribute
+var attributeTolerances = map[string]float64{
+	"colorHue":         1,
+	"colorSaturation":  0.02,
+	"colorTemperature": 50,
+}
+
+// attributeFunc computes the attributes to be set for a single device
+type attributeFunc func(device *client.Device) map[string]interface{}
+
+// onCmd represents the on command
+var onCmd = &cobra.Command{
+	Use:   "on <device|room>",
+	Short: "Switch on a device or all lights and outlets in a room",
+	Long: `Switches on the device or all devices in the room with the specified id or name.
+
+Examples:
+
+ikea on "Desk Lamp"
+
+ikea on Kitchen --transition 2s --wait`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDevices(cmd, args[0], "isOn", "switched on", func(device *client.Device) map[string]interface{} {
+			return map[string]interface{}{"isOn": true}
+		})
+	},
+}
+
+// offCmd represents the off command
+var offCmd = &cobra.Command{
+	Use:   "off <device|room>",
+	Short: "Switch off a device or all lights and outlets in a room",
+	Long:  `Switches off the device or all devices in the room with the specified id or name.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDevices(cmd, args[0], "isOn", "switched off", func(device *client.Device) map[string]interface{} {
+			return map[string]interface{}{"isOn": false}
+		})
+	},
+}
+
+// toggleCmd represents the toggle command
+var toggleCmd = &cobra.Command{
+	Use:   "toggle <device|room>",
+	Short: "Toggle a device or all lights and outlets in a room",
+	Long: `Toggles the device or all devices in the room with the specified id or name. If at least one device in the
+room is switched on, all devices are switched off, otherwise all devices are switched on.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var newState *bool
+		return controlDevices(cmd, args[0], "isOn", "toggled", func(device *client.Device) map[string]interface{} {
+			// All devices of a room are switched to the same state
+			return map[string]interface{}{"isOn": *newState}
+		}, func(devices []*client.Device) {
+			anyOn := false
+			for _, device := range devices {
+				if isOn, isBool := device.Attributes["isOn"].(bool); isBool && isOn {
+					anyOn = true
+				}
+			}
+			state := !anyOn
+			newState = &state
+		})
+	},
+}
+
+// dimCmd represents the dim command
+var dimCmd = &cobra.Command{
+	Use:   "dim <device|room> <percent>",
+	Short: "Set the brightness of a light or all lights in a room",
+	Long: `Sets the brightness of the light or all lights in the room with the specified id or name to a value
+between 1 and 100 percent.
+
+Examples:
+
+ikea dim "Desk Lamp" 40
+
+ikea dim Kitchen 100 --transition 5s`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if _, err := parsePercent(args[1], 1); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, _ := parsePercent(args[1], 1)
+		return controlDevices(cmd, args[0], "lightLevel", fmt.Sprintf("dimmed to %d%%", level), func(device *client.Device) map[string]interface{} {
+			return map[string]interface{}{"lightLevel": level}
+		})
+	},
+}
+
+// colorCmd represents the color command
+var colorCmd = &cobra.Command{
+	Use:   "color <device|room> <kelvin|hex|name>",
+	Short: "Set the color or color temperature of a light or all lights in a room",
+	Long: `Sets the color of the light or all lights in the room with the specified id or name. The color can be
+specified as color temperature in kelvin (e.g. 2700 or 2700K), as hex code (e.g. #ff8000) or by name.
+
+Supported names: warm, neutral, cold, red, orange, yellow, green, cyan, blue, purple, magenta, pink
+
+Examples:
+
+ikea color "Desk Lamp" 2700K
+
+ikea color Kitchen "#ff8000"
+
+ikea color Kitchen warm`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if _, err := parseColor(args[1]); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		color, _ := parseColor(args[1])
+		attribute := "colorHue"
+		if _, isTemperature := color["colorTemperature"]; isTemperature {
+			attribute = "colorTemperature"
+		}
+		return controlDevices(cmd, args[0], attribute, fmt.Sprintf("colored %s", args[1]), func(device *client.Device) map[string]interface{} {
+			attributes := make(map[string]interface{}, len(color))
+			for name, value := range color {
+				attributes[name] = value
+			}
+			if kelvin, isTemperature := attributes["colorTemperature"].(int); isTemperature {
+				attributes["colorTemperature"] = clampColorTemperature(device, kelvin)
+			}
+			return attributes
+		})
+	},
+}
+
+// blindCmd represents the blind command
+var blindCmd = &cobra.Command{
+	Use:   "blind <device|room> <percent>",
+	Short: "Move a blind or all blinds in a room to a position",
+	Long: `Moves the blind or all blinds in the room with the specified id or name to the specified position,
+where 0 percent means fully open and 100 percent means fully closed.
+
+Examples:
+
+ikea blind Bedroom 100
+
+ikea blind "Living Room Blind" 50 --wait`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if _, err := parsePercent(args[1], 0); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, _ := parsePercent(args[1], 0)
+		return controlDevices(cmd, args[0], "blindsTargetLevel", fmt.Sprintf("moved to %d%%", level), func(device *client.Device) map[string]interface{} {
+			return map[string]interface{}{"blindsTargetLevel": level}
+		})
+	},
+}
+
+func init() {
+	for _, controlCmd := range []*cobra.Command{onCmd, offCmd, toggleCmd, dimCmd, colorCmd, blindCmd} {
+		rootCmd.AddCommand(controlCmd)
+		controlCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+		controlCmd.Flags().DurationP("transition", "t", 0, "Defines the duration of the transition to the new state (e.g. 500ms or 2s)")
+		controlCmd.Flags().BoolP("wait", "w", false, "Wait until the hub confirms the new state of all devices")
+		controlCmd.Flags().Duration("timeout", 30*time.Second, "Defines how long to wait for the confirmation of the new state")
+	}
+}
+
+// controlDevices resolves the target to devices that can receive the specified attribute, sets the attributes
+// computed by attributesFor on each device and optionally waits for the hub to confirm the new state.
+// The optional prepare functions are called with the resolved devices before any attribute is computed.
+func controlDevices(cmd *cobra.Command, target, attribute, action string, attributesFor attributeFunc, prepare ...func([]*client.Device)) error {
+	usedContext, usedContextName, err := getContext(cmd)
+	if err != nil {
+		return fmt.Errorf("could not get context: %w", err)
+	}
+	transition, _ := cmd.Flags().GetDuration("transition")
+	wait, _ := cmd.Flags().GetBool("wait")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	dirigeraClient := getDirigeraClient(usedContext)
+	devices, err := resolveTargetDevices(dirigeraClient, target, attribute)
+	if err != nil {
+		return err
+	}
+	for _, prepareFunc := range prepare {
+		prepareFunc(devices)
+	}
+
+	expectedStates := make(map[string]map[string]interface{}, len(devices))
+	for _, device := range devices {
+		expectedStates[device.ID] = attributesFor(device)
+	}
+
+	var waiter *stateWaiter
+	if wait {
+		waiter = startStateWaiter(dirigeraClient, expectedStates)
+		defer waiter.stop()
+	}
+
+	for _, device := range devices {
+		if err := dirigeraClient.SetDeviceAttributes(device.ID, expectedStates[device.ID], transition); err != nil {
+			return fmt.Errorf("could not update device %s in %s: %w", describeDevice(device), usedContextName, err)
+		}
+	}
+
+	if waiter != nil {
+		if err := waiter.wait(timeout); err != nil {
+			return err
+		}
+	}
+
+	for _, device := range devices {
+		fmt.Printf("Device %s %s in %s\n", describeDevice(device), action, usedContextName)
+	}
+
+	return nil
+}
+
+// resolveTargetDevices returns the device with the specified id or name or all devices in the room with the specified
+// id or name. Only devices that can receive the specified attribute are returned.
+func resolveTargetDevices(dirigeraClient client.Client, target, attribute string) ([]*client.Device, error) {
+	devices, err := dirigeraClient.ListDevices()
+	if err != nil {
+		return nil, fmt.Errorf("could not list devices: %w", err)
+	}
+
+	for _, device := range devices {
+		if device.ID == target || strings.EqualFold(device.CustomName(), target) {
+			if !device.CanReceive(attribute) {
+				return nil, fmt.Errorf("device %s does not support %s", describeDevice(device), attribute)
+			}
+			return []*client.Device{device}, nil
+		}
+	}
+
+	var roomDevices []*client.Device
+	roomFound := false
+	for _, device := range devices {
+		if device.Room.ID == target || strings.EqualFold(device.Room.Name, target) {
+			roomFound = true
+			if device.CanReceive(attribute) {
+				roomDevices = append(roomDevices, device)
+			}
+		}
+	}
+	if !roomFound {
+		return nil, fmt.Errorf("no device or room found with id or name %s", target)
+	}
+	if len(roomDevices) == 0 {
+		return nil, fmt.Errorf("no device in room %s supports %s", target, attribute)
+	}
+
+	return roomDevices, nil
+}
+
+func describeDevice(device *client.Device) string {
+	if name := device.CustomName(); name != "" {
+		return fmt.Sprintf("%s (%s)", name, device.ID)
+	}
+
+	return device.ID
+}
+
+func parsePercent(value string, minimum int) (int, error) {
+	percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || percent < minimum || percent > 100 {
+		return 0, fmt.Errorf("invalid percentage %s: must be a number between %d and 100", value, minimum)
+	}
+
+	return percent, nil
+}
+
+// parseColor converts a kelvin value, a hex code or a color name into the attributes of a light.
+func parseColor(value string) (map[string]interface{}, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if kelvin, found := colorTemperatureNames[normalized]; found {
+		return map[string]interface{}{"colorTemperature": kelvin}, nil
+	}
+	if kelvin, err := strconv.Atoi(strings.TrimSuffix(normalized, "k")); err == nil {
+		if kelvin < 1000 || kelvin > 10000 {
+			return nil, fmt.Errorf("invalid color temperature %s: must be between 1000 and 10000 kelvin", value)
+		}
+		return map[string]interface{}{"colorTemperature": kelvin}, nil
+	}
+	if hex, found := colorNames[normalized]; found {
+		normalized = hex
+	}
+	normalized = strings.TrimPrefix(normalized, "#")
+	if len(normalized) != 6 {
+		return nil, fmt.Errorf("invalid color %s: must be a kelvin value, a hex code or a color name", value)
+	}
+	rgb, err := strconv.ParseUint(normalized, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %s: must be a kelvin value, a hex code or a color name", value)
+	}
+	hue, saturation := rgbToHueSaturation(float64(rgb>>16&0xff)/255, float64(rgb>>8&0xff)/255, float64(rgb&0xff)/255)
+
+	return map[string]interface{}{"colorHue": hue, "colorSaturation": saturation}, nil
+}
+
+func rgbToHueSaturation(r, g, b float64) (float64, float64) {
+	maximum := math.Max(r, math.Max(g, b))
+	minimum := math.Min(r, math.Min(g, b))
+	delta := maximum - minimum
+	if maximum == 0 || delta == 0 {
+		return 0, 0
+	}
+
+	var hue float64
+	switch maximum {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	return math.Round(hue), math.Round(delta/maximum*100) / 100
+}
+
+// clampColorTemperature limits the color temperature to the range supported by the device.
+// The hub reports the range with colorTemperatureMin being the coldest and colorTemperatureMax the warmest value.
+func clampColorTemperature(device *client.Device, kelvin int) int {
+	first, hasFirst := toFloat(device.Attributes["colorTemperatureMin"])
+	second, hasSecond := toFloat(device.Attributes["colorTemperatureMax"])
+	if !hasFirst || !hasSecond {
+		return kelvin
+	}
+
+	return int(math.Max(math.Min(first, second), math.Min(math.Max(first, second), float64(kelvin))))
+}
+
+// stateWaiter listens for deviceStateChanged events and tracks which devices reached the expected state.
+type stateWaiter struct {
+	dirigeraClient client.Client
+	mutex          sync.Mutex
+	pending        map[string]map[string]interface{}
+	changed        chan struct{}
+}
+
+func startStateWaiter(dirigeraClient client.Client, expectedStates map[string]map[string]interface{}) *stateWaiter {
+	waiter := &stateWaiter{
+		dirigeraClient: dirigeraClient,
+		pending:        make(map[string]map[string]interface{}, len(expectedStates)),
+		changed:        make(chan struct{}, 1),
+	}
+	for deviceID, attributes := range expectedStates {
+		pendingAttributes := make(map[string]interface{}, len(attributes))
+		for name, value := range attributes {
+			pendingAttributes[name] = value
+		}
+		waiter.pending[deviceID] = pendingAttributes
+	}
+
+	dirigeraClient.RegisterEventHandler(func(event client.Event) {
+		waiter.confirm(event.Device.ID, event.Device.Attributes)
+	}, "deviceStateChanged")
+	go func() {
+		_ = dirigeraClient.ListenForEvents()
+	}()
+
+	return waiter
+}
+
+func (w *stateWaiter) confirm(deviceID string, attributes map[string]interface{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	pendingAttributes, found := w.pending[deviceID]
+	if !found {
+		return
+	}
+	for name, expected := range pendingAttributes {
+		if actual, reported := attributes[name]; reported && attributeMatches(name, expected, actual) {
+			delete(pendingAttributes, name)
+		}
+	}
+	if len(pendingAttributes) == 0 {
+		delete(w.pending, deviceID)
+	}
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *stateWaiter) done() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.pending) == 0
+}
+
+// wait blocks until all devices reached the expected state or the timeout expired. Events received before the
+// websocket connection was established are covered by reading the state of pending devices periodically.
+func (w *stateWaiter) wait(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for !w.done() {
+		select {
+		case <-w.changed:
+		case <-ticker.C:
+			w.mutex.Lock()
+			deviceIDs := make([]string, 0, len(w.pending))
+			for deviceID := range w.pending {
+				deviceIDs = append(deviceIDs, deviceID)
+			}
+			w.mutex.Unlock()
+			for _, deviceID := range deviceIDs {
+				if device, err := w.dirigeraClient.GetDevice(deviceID); err == nil {
+					w.confirm(device.ID, device.Attributes)
+				}
+			}
+		case <-timer.C:
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			deviceIDs := make([]string, 0, len(w.pending))
+			for deviceID := range w.pending {
+				deviceIDs = append(deviceIDs, deviceID)
+			}
+			return fmt.Errorf("timed out after %s waiting for the new state of devices %s", timeout, strings.Join(deviceIDs, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (w *stateWaiter) stop() {
+	_ = w.dirigeraClient.StopEventListening()
+}
+
+func attributeMatches(name string, expected, actual interface{}) bool {
+	expectedNumber, expectedIsNumber := toFloat(expected)
+	actualNumber, actualIsNumber := toFloat(actual)
+	if expectedIsNumber && actualIsNumber {
+		tolerance, found := attributeTolerances[name]
+		if !found {
+			tolerance = 0.5
+		}
+		return math.Abs(expectedNumber-actualNumber) <= tolerance
+	}
+
+	return expected == actual
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	default:
+		return 0, false
+	}
+}
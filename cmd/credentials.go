@@ -0,0 +1,384 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	credentialStoreKeyring       = "keyring"
+	credentialStoreFile          = "file"
+	credentialStoreEncryptedFile = "encrypted-file"
+	credentialStoreEnv           = "env"
+)
+
+var credentialStoreNames = []string{credentialStoreKeyring, credentialStoreFile, credentialStoreEncryptedFile, credentialStoreEnv}
+
+var errCredentialNotFound = errors.New("access token not found")
+
+// CredentialStore persists the access tokens of the contexts outside the config file.
+type CredentialStore interface {
+	Get(contextName string) (string, error)
+	Set(contextName, token string) error
+	Delete(contextName string) error
+	Describe() string
+}
+
+// getCredentialStore returns the store with the specified name, the OS keyring is used if no name is specified.
+func getCredentialStore(name string) (CredentialStore, error) {
+	switch name {
+	case "", credentialStoreKeyring:
+		return &keyringStore{}, nil
+	case credentialStoreFile:
+		path, err := credentialFilePath(".yaml")
+		if err != nil {
+			return nil, err
+		}
+		return &fileStore{path: path}, nil
+	case credentialStoreEncryptedFile:
+		path, err := credentialFilePath(".age")
+		if err != nil {
+			return nil, err
+		}
+		return &encryptedFileStore{fileStore: fileStore{path: path}}, nil
+	case credentialStoreEnv:
+		return &envStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential store %s (supported: %s)", name, strings.Join(credentialStoreNames, ", "))
+	}
+}
+
+// loadAccessToken reads the access token of the context from its credential store if not already loaded.
+func loadAccessToken(contextName string, context *Context) error {
+	if context.AccessToken != "" {
+		return nil
+	}
+	store, err := getCredentialStore(context.CredentialStore)
+	if err != nil {
+		return err
+	}
+	token, err := store.Get(contextName)
+	if err != nil {
+		return fmt.Errorf("could not read access token of context %s from %s: %w", contextName, store.Describe(), err)
+	}
+	context.AccessToken = token
+
+	return nil
+}
+
+func credentialFilePath(extension string) (string, error) {
+	directory := ""
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		directory = filepath.Dir(configFile)
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not determine home directory: %w", err)
+		}
+		directory = home
+	}
+
+	return filepath.Join(directory, ".ikea-dirigera-cli-tokens"+extension), nil
+}
+
+// keyringStore keeps the access tokens in the keyring of the operating system.
+type keyringStore struct{}
+
+func (s *keyringStore) Get(contextName string) (string, error) {
+	token, err := keyring.Get(appName, contextName)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", errCredentialNotFound
+	}
+
+	return token, err
+}
+
+func (s *keyringStore) Set(contextName, token string) error {
+	return keyring.Set(appName, contextName, token)
+}
+
+func (s *keyringStore) Delete(contextName string) error {
+	err := keyring.Delete(appName, contextName)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return errCredentialNotFound
+	}
+
+	return err
+}
+
+func (s *keyringStore) Describe() string {
+	return "the keyring"
+}
+
+// envStore reads the access tokens from environment variables named IKEA_TOKEN_<CONTEXT>. The variables can not
+// be changed by the CLI, so setting and deleting tokens is not supported.
+type envStore struct{}
+
+func (s *envStore) Get(contextName string) (string, error) {
+	token := os.Getenv(tokenVariableName(contextName))
+	if token == "" {
+		return "", errCredentialNotFound
+	}
+
+	return token, nil
+}
+
+func (s *envStore) Set(contextName, token string) error {
+	return fmt.Errorf("tokens can not be stored in environment variables, set %s manually", tokenVariableName(contextName))
+}
+
+func (s *envStore) Delete(contextName string) error {
+	return errCredentialNotFound
+}
+
+func (s *envStore) Describe() string {
+	return "environment variables"
+}
+
+// tokenVariableName returns the environment variable for the token of a context, e.g. IKEA_TOKEN_MY_HUB for my-hub.
+func tokenVariableName(contextName string) string {
+	name := strings.Map(func(character rune) rune {
+		if (character >= 'A' && character <= 'Z') || (character >= '0' && character <= '9') {
+			return character
+		}
+		return '_'
+	}, strings.ToUpper(contextName))
+
+	return "IKEA_TOKEN_" + name
+}
+
+// fileStore keeps the access tokens unencrypted in a YAML file readable only by the owner.
+type fileStore struct {
+	path string
+}
+
+func (s *fileStore) Get(contextName string) (string, error) {
+	tokens, err := s.readTokens(s.readPlain)
+	if err != nil {
+		return "", err
+	}
+	token, found := tokens[contextName]
+	if !found {
+		return "", errCredentialNotFound
+	}
+
+	return token, nil
+}
+
+func (s *fileStore) Set(contextName, token string) error {
+	return s.update(s.readPlain, s.writePlain, func(tokens map[string]string) bool {
+		tokens[contextName] = token
+		return true
+	})
+}
+
+func (s *fileStore) Delete(contextName string) error {
+	found := false
+	err := s.update(s.readPlain, s.writePlain, func(tokens map[string]string) bool {
+		_, found = tokens[contextName]
+		delete(tokens, contextName)
+		return found
+	})
+	if err == nil && !found {
+		return errCredentialNotFound
+	}
+
+	return err
+}
+
+func (s *fileStore) Describe() string {
+	return "the file " + s.path
+}
+
+func (s *fileStore) readTokens(read func() ([]byte, error)) (map[string]string, error) {
+	tokens := make(map[string]string)
+	data, err := read()
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", s.path, err)
+	}
+	if tokens == nil {
+		tokens = make(map[string]string)
+	}
+
+	return tokens, nil
+}
+
+func (s *fileStore) update(read func() ([]byte, error), write func([]byte) error, change func(tokens map[string]string) bool) error {
+	tokens, err := s.readTokens(read)
+	if err != nil {
+		return err
+	}
+	if !change(tokens) {
+		return nil
+	}
+	data, err := yaml.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("error encoding tokens: %w", err)
+	}
+
+	return write(data)
+}
+
+// readPlain refuses to read files that are accessible by other users, like ssh does for private keys.
+func (s *fileStore) readPlain() ([]byte, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("permissions %o of %s are too open, must be 600", info.Mode().Perm(), s.path)
+	}
+
+	return os.ReadFile(s.path)
+}
+
+func (s *fileStore) writePlain(data []byte) error {
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %w", s.path, err)
+	}
+
+	return os.Chmod(s.path, 0o600)
+}
+
+// encryptedFileStore keeps the access tokens in a YAML file encrypted with age using a passphrase. The passphrase
+// is read from the environment variable IKEA_PASSPHRASE or asked for interactively.
+type encryptedFileStore struct {
+	fileStore
+}
+
+var (
+	passphrase      string
+	passphraseMutex sync.Mutex
+)
+
+func (s *encryptedFileStore) Get(contextName string) (string, error) {
+	tokens, err := s.readTokens(s.readEncrypted)
+	if err != nil {
+		return "", err
+	}
+	token, found := tokens[contextName]
+	if !found {
+		return "", errCredentialNotFound
+	}
+
+	return token, nil
+}
+
+func (s *encryptedFileStore) Set(contextName, token string) error {
+	return s.update(s.readEncrypted, s.writeEncrypted, func(tokens map[string]string) bool {
+		tokens[contextName] = token
+		return true
+	})
+}
+
+func (s *encryptedFileStore) Delete(contextName string) error {
+	found := false
+	err := s.update(s.readEncrypted, s.writeEncrypted, func(tokens map[string]string) bool {
+		_, found = tokens[contextName]
+		delete(tokens, contextName)
+		return found
+	})
+	if err == nil && !found {
+		return errCredentialNotFound
+	}
+
+	return err
+}
+
+func (s *encryptedFileStore) Describe() string {
+	return "the encrypted file " + s.path
+}
+
+func (s *encryptedFileStore) readEncrypted() ([]byte, error) {
+	data, err := s.readPlain()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	identity, err := age.NewScryptIdentity(secret)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %s: %w", s.path, err)
+	}
+
+	return io.ReadAll(reader)
+}
+
+func (s *encryptedFileStore) writeEncrypted(data []byte) error {
+	secret, err := getPassphrase()
+	if err != nil {
+		return err
+	}
+	recipient, err := age.NewScryptRecipient(secret)
+	if err != nil {
+		return err
+	}
+	encrypted := &bytes.Buffer{}
+	writer, err := age.Encrypt(encrypted, recipient)
+	if err != nil {
+		return fmt.Errorf("error encrypting tokens: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("error encrypting tokens: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error encrypting tokens: %w", err)
+	}
+
+	return s.writePlain(encrypted.Bytes())
+}
+
+func getPassphrase() (string, error) {
+	passphraseMutex.Lock()
+	defer passphraseMutex.Unlock()
+
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if secret := os.Getenv("IKEA_PASSPHRASE"); secret != "" {
+		passphrase = secret
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no passphrase for the encrypted credential file, set IKEA_PASSPHRASE")
+	}
+	_, _ = fmt.Fprint(os.Stderr, "Passphrase for the encrypted credential file: ")
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading passphrase: %w", err)
+	}
+	if len(secret) == 0 {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+	passphrase = string(secret)
+
+	return passphrase, nil
+}
@@ -4,11 +4,11 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"github.com/zalando/go-keyring"
 )
 
 // deleteCmd represents the delete command
@@ -23,52 +23,124 @@ var deleteContextCmd = &cobra.Command{
 	Use:     "context <name>",
 	Aliases: []string{"ctx", "c"},
 	Short:   "Remove the specified context from the CLI config and the related user from the IKEA DIRIGERA Hub",
-	Args:    cobra.ExactArgs(1),
+	Long: `Removes the specified context from the CLI config, its access token from the credential store and the related
+user from the IKEA DIRIGERA Hub. If the user can not be determined or removed, nothing is changed unless --force is
+specified. With --force the context is removed even if the hub is unreachable (offline mode).
+
+Examples:
+
+ikea delete context my-context
+
+ikea delete context my-context --keep-user
+
+ikea delete context my-context --force`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		contextName := args[0]
+		keepUser, _ := cmd.Flags().GetBool("keep-user")
+		force, _ := cmd.Flags().GetBool("force")
 		context, found := appConfig.Contexts[contextName]
 		if !found {
-			return fmt.Errorf("unknown context: %s", contextName)
+			return unknownContextError(contextName)
 		}
-		dirigeraClient := getDirigeraClient(context)
-		currentUser, err := dirigeraClient.GetCurrentUser()
+		store, err := getCredentialStore(context.CredentialStore)
 		if err != nil {
-			fmt.Printf("warning: could not get current user: %v", err)
+			return err
 		}
-		err = dirigeraClient.DeleteUser(currentUser.ID)
-		if err != nil {
-			fmt.Printf("warning: could not delete user: %v", err)
+		summary := &deletionSummary{}
+		if !keepUser {
+			if err := loadAccessToken(contextName, context); err != nil && !force {
+				return fmt.Errorf("%w, nothing removed (use --force to remove the context anyway or --keep-user to keep the user)", err)
+			}
 		}
 
-		delete(appConfig.Contexts, contextName)
-		viper.Set("contexts", appConfig.Contexts)
+		// Determine the user before changing anything
+		var currentUser *client.User
+		dirigeraClient := getDirigeraClient(context)
+		if keepUser {
+			summary.kept("user on the hub (--keep-user)")
+		} else {
+			currentUser, err = dirigeraClient.GetCurrentUser()
+			if err != nil {
+				if !force {
+					return fmt.Errorf("could not get user of context %s, nothing removed (use --force to remove the context anyway or --keep-user to keep the user): %w", contextName, err)
+				}
+				summary.failed("user on the hub (hub unreachable, offline mode)", err)
+			}
+		}
 
-		if appConfig.CurrentContext == contextName {
+		// Remove the context from the config first, so that a failure leaves everything unchanged
+		wasCurrentContext := appConfig.CurrentContext == contextName
+		delete(appConfig.Contexts, contextName)
+		if wasCurrentContext {
 			appConfig.CurrentContext = ""
-			viper.Set("current_context", appConfig.CurrentContext)
 		}
+		if err := writeConfig(); err != nil {
+			restoreContext(contextName, context, wasCurrentContext)
+			return fmt.Errorf("could not remove context %s, nothing removed: %w", contextName, err)
+		}
+		summary.removed(fmt.Sprintf("context %s from the CLI config", contextName))
 
-		err = viper.WriteConfig()
-		if err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				if err := viper.SafeWriteConfig(); err != nil {
-					return fmt.Errorf("error writing config: %w", err)
+		// Remove the user from the hub and restore the context if this fails
+		if currentUser != nil {
+			if err := dirigeraClient.DeleteUser(currentUser.ID); err != nil {
+				if !force {
+					restoreContext(contextName, context, wasCurrentContext)
+					if restoreErr := writeConfig(); restoreErr != nil {
+						return fmt.Errorf("could not delete user %s and could not restore context %s: %w", currentUser.ID, contextName, errors.Join(err, restoreErr))
+					}
+					return fmt.Errorf("could not delete user %s, nothing removed (use --force to remove the context anyway): %w", currentUser.ID, err)
 				}
+				summary.failed(fmt.Sprintf("user %s (%s) on the hub", currentUser.ID, currentUser.Name), err)
 			} else {
-				return fmt.Errorf("error writing config: %w", err)
+				summary.removed(fmt.Sprintf("user %s (%s) from the hub", currentUser.ID, currentUser.Name))
 			}
 		}
 
-		err = keyring.Delete(appName, contextName)
-		if err != nil {
-			return fmt.Errorf("error removing token from keyring: %w", err)
+		// Remove the access token, a missing token is not an error
+		if err := store.Delete(contextName); err != nil && !errors.Is(err, errCredentialNotFound) {
+			summary.failed("access token in "+store.Describe(), err)
+		} else if err == nil {
+			summary.removed("access token from " + store.Describe())
 		}
-		fmt.Printf("Deleted context %s and user %s\n", contextName, currentUser.ID)
+
+		summary.print(contextName)
 
 		return nil
 	},
 }
 
+func restoreContext(contextName string, context *Context, wasCurrentContext bool) {
+	appConfig.Contexts[contextName] = context
+	if wasCurrentContext {
+		appConfig.CurrentContext = contextName
+	}
+}
+
+// deletionSummary collects what was removed, kept or could not be removed while deleting a context.
+type deletionSummary struct {
+	lines []string
+}
+
+func (s *deletionSummary) removed(what string) {
+	s.lines = append(s.lines, "  removed: "+what)
+}
+
+func (s *deletionSummary) kept(what string) {
+	s.lines = append(s.lines, "  kept:    "+what)
+}
+
+func (s *deletionSummary) failed(what string, err error) {
+	s.lines = append(s.lines, fmt.Sprintf("  failed:  %s: %v", what, err))
+}
+
+func (s *deletionSummary) print(contextName string) {
+	fmt.Printf("Deleted context %s:\n", contextName)
+	for _, line := range s.lines {
+		fmt.Println(line)
+	}
+}
+
 // deleteUserCmd represents the delete user command
 var deleteUserCmd = &cobra.Command{
 	Use:     "user <id>",
@@ -96,6 +168,8 @@ func init() {
 	rootCmd.AddCommand(deleteCmd)
 
 	deleteCmd.AddCommand(deleteContextCmd)
+	deleteContextCmd.Flags().Bool("keep-user", false, "Keep the user in the IKEA DIRIGERA Hub and only remove the context")
+	deleteContextCmd.Flags().BoolP("force", "f", false, "Remove the context even if the user can not be removed from the hub")
 
 	deleteCmd.AddCommand(deleteUserCmd)
 	deleteUserCmd.Flags().StringP("context", "c", "", "Defines the context to use")
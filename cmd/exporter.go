@@ -0,0 +1,105 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/salex-org/ikea-dirigera-client/pkg/exporter"
+	"github.com/spf13/cobra"
+)
+
+const exporterShutdownTimeout = 5 * time.Second
+
+// exporterCmd represents the exporter command
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Provide metrics of the IKEA DIRIGERA Hub for Prometheus",
+	Long: `Serves the state of the devices and the hub as Prometheus metrics until stopped by Ctrl-C. The state is read
+once at start and updated from the events of the hub afterward, so scraping does not cause requests to the hub.
+
+Provided metrics include the measured values of sensors, light levels, on/off states, blind levels, battery
+levels, power and energy of outlets, the reachability of devices, hub information and the health of the event
+stream.
+
+Examples:
+
+ikea exporter
+
+ikea exporter --listen :9842 --path /metrics`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		path, _ := cmd.Flags().GetString("path")
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+
+		var metricsExporter *exporter.Exporter
+		dirigeraClient := getDirigeraClient(usedContext, client.WithConnectionHandler(func(connected bool, err error) {
+			metricsExporter.HandleConnection(connected, err)
+		}))
+		metricsExporter = exporter.New(dirigeraClient, logger)
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			metricsExporter,
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		mux := http.NewServeMux()
+		mux.Handle(path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		server := &http.Server{
+			Addr:              listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		serving := make(chan error, 1)
+		go func() {
+			fmt.Printf("Serving metrics of context %s on %s%s...\n", usedContextName, listen, path)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serving <- err
+			}
+			close(serving)
+		}()
+		running := make(chan error, 1)
+		go func() {
+			running <- metricsExporter.Run(ctx)
+		}()
+
+		select {
+		case err = <-serving:
+			stop()
+			<-running
+		case err = <-running:
+		}
+		shutdownContext, cancel := context.WithTimeout(context.Background(), exporterShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownContext)
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exporterCmd)
+	exporterCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	exporterCmd.Flags().String("listen", ":9842", "Defines the address to serve the metrics on")
+	exporterCmd.Flags().String("path", "/metrics", "Defines the HTTP path of the metrics")
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/salex-org/ikea-dirigera-client/pkg/history"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Query past events of the IKEA DIRIGERA Hub",
+	Long: `Shows the events stored by "ikea history record", filtered by device, room, type, attribute and time range.
+Devices and rooms are selected by ID or name. Attributes are selected by name or as name=value.
+
+Times are durations before now (e.g. 12h), times of the last 24 hours (e.g. 22:00), dates (e.g. 2025-01-31) or
+date and time (e.g. "2025-01-31 22:00" or RFC 3339). If both --since and --until are times, --until is the first
+time after --since, so --since 22:00 --until 06:00 selects the last night even before 06:00.
+
+Examples:
+
+ikea history --device "Front door" --attribute isOpen=true --since 22:00 --until 06:00
+
+ikea history --room Kitchen --type deviceStateChanged --since 1h -o json
+
+ikea history --device "Front door" --attribute isOpen=true --limit 1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query, err := getHistoryQuery(cmd, time.Now())
+		if err != nil {
+			return err
+		}
+		path := getHistoryPath(cmd)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("no history found at %s, record events with \"ikea history record\"", path)
+		}
+		store, err := history.OpenReadOnly(path)
+		if err != nil {
+			return err
+		}
+		records, err := store.Query(query)
+		if err != nil {
+			return fmt.Errorf("could not query history: %w", err)
+		}
+		if records == nil {
+			records = []history.Record{}
+		}
+
+		return printOutput(cmd, records, func(writer io.Writer) {
+			t := table.NewWriter()
+			t.SetOutputMirror(writer)
+			t.AppendHeader(table.Row{"Time", "Type", "Room", "Device", "Attributes"})
+			for _, record := range records {
+				device := record.Device
+				if device == "" {
+					device = record.DeviceID
+				}
+				t.AppendRow(table.Row{
+					record.Time.Local().Format(time.DateTime), record.Type, record.Room, device, formatAttributes(record.Attributes),
+				})
+			}
+			t.SetStyle(table.StyleDefault)
+			t.SetAutoIndex(true)
+			_, _ = fmt.Fprintf(writer, "found %d events:\n", len(records))
+			t.Render()
+		})
+	},
+}
+
+// historyRecordCmd represents the history record command
+var historyRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Store the events of the IKEA DIRIGERA Hub in the history",
+	Long: `Stores all events of the hub with the name and room of the device until stopped by Ctrl-C. Events older than
+--max-age and the oldest events exceeding --max-events are removed every hour. The history can be queried while
+events are recorded.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxAge, _ := cmd.Flags().GetDuration("max-age")
+		maxEvents, _ := cmd.Flags().GetInt("max-events")
+		path := getHistoryPath(cmd)
+		store, err := history.Open(path)
+		if err != nil {
+			return err
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		collector := history.NewCollector(getDirigeraClient(usedContext), store, history.Retention{
+			MaxAge:     maxAge,
+			MaxRecords: maxEvents,
+		}, logger)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("Recording events of context %s to %s...\n", usedContextName, path)
+		if err := collector.Run(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Recording stopped")
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.PersistentFlags().String("store", "", "Defines the file of the history (default $HOME/.ikea-dirigera-cli-history.db)")
+	historyCmd.Flags().StringSlice("device", nil, "Selects events of the devices with the specified IDs or names")
+	historyCmd.Flags().StringSlice("room", nil, "Selects events of devices in the rooms with the specified IDs or names")
+	historyCmd.Flags().StringSlice("type", nil, "Selects events of the specified types, e.g. deviceStateChanged")
+	historyCmd.Flags().StringSlice("attribute", nil, "Selects events changing the attribute, specified as name or name=value")
+	historyCmd.Flags().String("since", "", "Selects events since the specified time")
+	historyCmd.Flags().String("until", "", "Selects events before the specified time")
+	historyCmd.Flags().Int("limit", 0, "Shows only the specified number of the latest events")
+	historyCmd.Flags().StringP("output", "o", "text", "Defines the format of the output (text, json or yaml)")
+
+	historyCmd.AddCommand(historyRecordCmd)
+	historyRecordCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	historyRecordCmd.Flags().Duration("max-age", 30*24*time.Hour, "Defines how long events are kept (0 keeps them forever)")
+	historyRecordCmd.Flags().Int("max-events", 0, "Defines the maximum number of stored events (0 for no limit)")
+}
+
+func getHistoryPath(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("store"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	cobra.CheckErr(err)
+
+	return filepath.Join(home, ".ikea-dirigera-cli-history.db")
+}
+
+// getHistoryQuery reads the query from the flags. Relative times are resolved from now.
+func getHistoryQuery(cmd *cobra.Command, now time.Time) (history.Query, error) {
+	var query history.Query
+	query.Devices, _ = cmd.Flags().GetStringSlice("device")
+	query.Rooms, _ = cmd.Flags().GetStringSlice("room")
+	query.Types, _ = cmd.Flags().GetStringSlice("type")
+	query.Attributes, _ = cmd.Flags().GetStringSlice("attribute")
+	query.Limit, _ = cmd.Flags().GetInt("limit")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+
+	var err error
+	if query.Since, err = parseHistoryTime(since, now); err != nil {
+		return query, fmt.Errorf("invalid --since: %w", err)
+	}
+	untilReference := now
+	if isClockTime(since) && isClockTime(until) {
+		// A range like 22:00 to 06:00 spans midnight, so it ends at the first time after its start
+		untilReference = query.Since.AddDate(0, 0, 1)
+	}
+	if query.Until, err = parseHistoryTime(until, untilReference); err != nil {
+		return query, fmt.Errorf("invalid --until: %w", err)
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return query, fmt.Errorf("--since %s must be before --until %s", query.Since.Format(time.DateTime), query.Until.Format(time.DateTime))
+	}
+
+	return query, nil
+}
+
+func isClockTime(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil
+}
+
+// parseHistoryTime parses durations before now, times of the last 24 hours, dates and date times in local time.
+// An empty value returns the zero time.
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if clock, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		result := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if result.After(now) {
+			result = result.AddDate(0, 0, -1)
+		}
+		return result, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", time.DateOnly} {
+		if result, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return result, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%s is no duration, time, date or date time", value)
+}
+
+func formatAttributes(attributes map[string]interface{}) string {
+	values := make([]string, 0, len(attributes))
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		values = append(values, fmt.Sprintf("%s=%v", name, attributes[name]))
+	}
+
+	return strings.Join(values, " ")
+}
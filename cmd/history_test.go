@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2025, time.January, 15, 12, 30, 0, 0, time.Local)
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"", time.Time{}},
+		{"2h", now.Add(-2 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"08:15", time.Date(2025, time.January, 15, 8, 15, 0, 0, time.Local)},
+		{"12:30", now},
+		// Times after now are taken from the day before
+		{"22:00", time.Date(2025, time.January, 14, 22, 0, 0, 0, time.Local)},
+		{"2025-01-10", time.Date(2025, time.January, 10, 0, 0, 0, 0, time.Local)},
+		{"2025-01-10 07:45", time.Date(2025, time.January, 10, 7, 45, 0, 0, time.Local)},
+		{"2025-01-10 07:45:30", time.Date(2025, time.January, 10, 7, 45, 30, 0, time.Local)},
+		{"2025-01-10T07:45:30Z", time.Date(2025, time.January, 10, 7, 45, 30, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		result, err := parseHistoryTime(test.value, now)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", test.value, err)
+			continue
+		}
+		if !result.Equal(test.expected) {
+			t.Errorf("expected %v for %q, got %v", test.expected, test.value, result)
+		}
+	}
+
+	for _, value := range []string{"yesterday", "25:00", "2025-13-01"} {
+		if _, err := parseHistoryTime(value, now); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestGetHistoryQuery(t *testing.T) {
+	now := time.Date(2025, time.January, 15, 12, 30, 0, 0, time.Local)
+	tests := []struct {
+		name          string
+		since         string
+		until         string
+		expectedSince time.Time
+		expectedUntil time.Time
+		fails         bool
+	}{
+		{name: "no range"},
+		{name: "duration", since: "1h", expectedSince: now.Add(-time.Hour)},
+		{
+			name:          "clock times of the same day",
+			since:         "08:00",
+			until:         "10:00",
+			expectedSince: time.Date(2025, time.January, 15, 8, 0, 0, 0, time.Local),
+			expectedUntil: time.Date(2025, time.January, 15, 10, 0, 0, 0, time.Local),
+		},
+		{
+			name:          "clock times across midnight",
+			since:         "22:00",
+			until:         "06:00",
+			expectedSince: time.Date(2025, time.January, 14, 22, 0, 0, 0, time.Local),
+			expectedUntil: time.Date(2025, time.January, 15, 6, 0, 0, 0, time.Local),
+		},
+		{
+			name:          "dates",
+			since:         "2025-01-01",
+			until:         "2025-01-02",
+			expectedSince: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.Local),
+			expectedUntil: time.Date(2025, time.January, 2, 0, 0, 0, 0, time.Local),
+		},
+		{name: "until before since", since: "2025-01-02", until: "2025-01-01", fails: true},
+		{name: "invalid since", since: "yesterday", fails: true},
+		{name: "invalid until", until: "tomorrow", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setHistoryFlag(t, "since", test.since)
+			setHistoryFlag(t, "until", test.until)
+			query, err := getHistoryQuery(historyCmd, now)
+			if test.fails {
+				if err == nil {
+					t.Errorf("expected error, got range %v to %v", query.Since, query.Until)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !query.Since.Equal(test.expectedSince) || !query.Until.Equal(test.expectedUntil) {
+				t.Errorf("expected range %v to %v, got %v to %v", test.expectedSince, test.expectedUntil, query.Since, query.Until)
+			}
+		})
+	}
+}
+
+func setHistoryFlag(t *testing.T, name, value string) {
+	t.Helper()
+	if err := historyCmd.Flags().Set(name, value); err != nil {
+		t.Fatalf("could not set --%s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		_ = historyCmd.Flags().Set(name, "")
+	})
+}
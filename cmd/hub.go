@@ -0,0 +1,217 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// hubCmd represents the hub command
+var hubCmd = &cobra.Command{
+	Use:   "hub",
+	Short: "Maintain the IKEA DIRIGERA Hub",
+}
+
+// hubUpdateCmd represents the hub update command
+var hubUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Check for and install a firmware update on the IKEA DIRIGERA Hub",
+	Long: `Lets the IKEA DIRIGERA Hub check for a new firmware and installs it after confirmation. The progress of the
+update is followed until the hub is back online with the new firmware version.
+
+Examples:
+
+ikea hub update
+
+ikea hub update --check-only
+
+ikea hub update --yes --timeout 1h`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checkOnly, _ := cmd.Flags().GetBool("check-only")
+		skipConfirmation, _ := cmd.Flags().GetBool("yes")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+
+		status, err := dirigeraClient.GetFirmwareUpdateStatus()
+		if err != nil {
+			return fmt.Errorf("could not get firmware update status: %w", err)
+		}
+		currentVersion := status.FirmwareVersion
+		fmt.Printf("using context: %s\n", usedContextName)
+		fmt.Printf("Current firmware: %s\n", currentVersion)
+		if status.InProgress() {
+			return fmt.Errorf("the hub is busy with a firmware update (state: %s), try again later", status.State)
+		}
+
+		fmt.Printf("Checking for firmware update...")
+		err = dirigeraClient.CheckFirmwareUpdate()
+		if err != nil {
+			fmt.Printf("failed\n")
+			return fmt.Errorf("could not check for firmware update: %w", err)
+		}
+		status, err = waitForFirmwareCheck(dirigeraClient, 2*time.Minute)
+		if err != nil {
+			fmt.Printf("failed\n")
+			return err
+		}
+		fmt.Printf("done\n")
+
+		if !status.UpdateAvailable() {
+			fmt.Printf("Firmware %s is up to date\n", currentVersion)
+			return nil
+		}
+		fmt.Printf("A firmware update is available (state: %s)\n", status.State)
+		if checkOnly {
+			return nil
+		}
+		if !skipConfirmation && !askForConfirmation("Install the firmware update now? The hub will restart during the update") {
+			fmt.Printf("Firmware update cancelled\n")
+			return nil
+		}
+
+		err = dirigeraClient.InstallFirmwareUpdate()
+		if err != nil {
+			return fmt.Errorf("could not install firmware update: %w", err)
+		}
+		fmt.Printf("Firmware update started\n")
+
+		newVersion, err := followFirmwareUpdate(dirigeraClient, currentVersion, timeout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Firmware updated from %s to %s\n", currentVersion, newVersion)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hubCmd)
+	hubCmd.PersistentFlags().StringP("context", "c", "", "Defines the context to use")
+
+	hubCmd.AddCommand(hubUpdateCmd)
+	hubUpdateCmd.Flags().Bool("check-only", false, "Only check for a firmware update without installing it")
+	hubUpdateCmd.Flags().BoolP("yes", "y", false, "Install an available update without asking for confirmation")
+	hubUpdateCmd.Flags().Duration("timeout", 30*time.Minute, "Defines how long to wait for the hub to come back with the new firmware")
+}
+
+// firmwareCheckStartTimeout is the time the hub gets to report a started check before the reported state is taken
+// as the result, e.g. if the check finished between two polls.
+const firmwareCheckStartTimeout = 10 * time.Second
+
+// waitForFirmwareCheck polls the firmware update status until the hub finished checking for an update. The state
+// before the check may still be reported right after it was started, so the check is only finished after
+// checkInProgress was reported or the hub did not report it in time.
+func waitForFirmwareCheck(dirigeraClient client.Client, timeout time.Duration) (*client.FirmwareUpdateStatus, error) {
+	started := time.Now()
+	deadline := started.Add(timeout)
+	checking := false
+	for {
+		status, err := dirigeraClient.GetFirmwareUpdateStatus()
+		if err != nil {
+			return nil, fmt.Errorf("could not get firmware update status: %w", err)
+		}
+		switch {
+		case status.State == "checkInProgress":
+			checking = true
+		case checking, time.Since(started) > firmwareCheckStartTimeout:
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for the firmware update check", timeout)
+		}
+		fmt.Printf(".")
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// firmwareProgress prints changes of the update state reported by events or polling only once.
+// Values missing in partial updates are taken from the previous report.
+type firmwareProgress struct {
+	mutex     sync.Mutex
+	lastState string
+	lastValue int
+}
+
+func (p *firmwareProgress) report(state string, progress int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if state == "" {
+		state = p.lastState
+	}
+	if progress < 0 {
+		progress = p.lastValue
+	}
+	if state == p.lastState && progress == p.lastValue {
+		return
+	}
+	p.lastState = state
+	p.lastValue = progress
+	fmt.Printf("%s: %d%%\n", state, progress)
+}
+
+// followFirmwareUpdate reports the update progress from hub events and polling and returns the new firmware version
+// as soon as the hub is reachable again with a version different from the previous one.
+func followFirmwareUpdate(dirigeraClient client.Client, previousVersion string, timeout time.Duration) (string, error) {
+	progress := &firmwareProgress{}
+	hub, err := dirigeraClient.GetHubStatus()
+	if err == nil {
+		dirigeraClient.RegisterEventHandler(func(event client.Event) {
+			if event.Device.ID != hub.ID {
+				return
+			}
+			state, hasState := event.Device.Attributes["otaState"].(string)
+			value, hasProgress := event.Device.Attributes["otaProgress"].(float64)
+			if !hasProgress {
+				value = -1
+			}
+			if hasState || hasProgress {
+				progress.report(state, int(value))
+			}
+		}, "deviceStateChanged")
+		go func() {
+			_ = dirigeraClient.ListenForEvents()
+		}()
+		defer func() {
+			_ = dirigeraClient.StopEventListening()
+		}()
+	}
+
+	deadline := time.Now().Add(timeout)
+	unreachable := false
+	for time.Now().Before(deadline) {
+		time.Sleep(5 * time.Second)
+		status, err := dirigeraClient.GetFirmwareUpdateStatus()
+		if err != nil {
+			if !unreachable {
+				fmt.Printf("Hub not reachable, probably restarting...\n")
+				unreachable = true
+			}
+			continue
+		}
+		if unreachable {
+			fmt.Printf("Hub reachable again\n")
+			unreachable = false
+		}
+		if status.State == "updateFailed" {
+			return "", fmt.Errorf("firmware update failed on the hub")
+		}
+		if status.FirmwareVersion != "" && status.FirmwareVersion != previousVersion {
+			return status.FirmwareVersion, nil
+		}
+		progress.report(status.State, status.Progress)
+	}
+
+	return "", fmt.Errorf("timed out after %s waiting for the hub to come back with a new firmware", timeout)
+}
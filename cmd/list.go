@@ -3,6 +3,11 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/salex-org/ikea-dirigera-client/pkg/client"
@@ -20,9 +25,25 @@ var listHubsCmd = &cobra.Command{
 	Use:     "hubs",
 	Aliases: []string{"hub", "h"},
 	Short:   "List all available IKEA DIRIGERA Hubs",
-	Long:    `Searches for IKEA DIRIGERA Hubs on the local network using mDNS.`,
+	Long: `Searches for IKEA DIRIGERA Hubs on the local network using mDNS. With --watch the search is repeated until
+stopped by Ctrl-C and hubs appearing or disappearing are reported.
+
+Examples:
+
+ikea list hubs --timeout 5s
+
+ikea list hubs --interface en0 --ipv6
+
+ikea list hubs --watch`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		hubs, err := client.Scan()
+		options, err := getScanOptions(cmd)
+		if err != nil {
+			return err
+		}
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			return watchHubs(cmd, options)
+		}
+		hubs, err := client.ScanWithOptions(cmd.Context(), options)
 		if err != nil {
 			return fmt.Errorf("could not scan for hubs: %w", err)
 		}
@@ -216,6 +237,11 @@ func init() {
 	listCmd.PersistentFlags().StringP("output", "o", "text", "Defines the format of the output (text or json)")
 
 	listCmd.AddCommand(listHubsCmd)
+	listHubsCmd.Flags().Duration("timeout", time.Second, "Defines how long to wait for answers of hubs")
+	listHubsCmd.Flags().String("interface", "", "Defines the network interface to use (default all interfaces)")
+	listHubsCmd.Flags().Bool("ipv6", false, "Search for hubs using IPv6 in addition to IPv4")
+	listHubsCmd.Flags().BoolP("watch", "w", false, "Search continuously and report hubs appearing or disappearing")
+	listHubsCmd.Flags().Duration("interval", 30*time.Second, "Defines the interval between searches when watching")
 	listCmd.AddCommand(listContextsCmd)
 
 	listCmd.AddCommand(listDevicesCmd)
@@ -230,3 +256,44 @@ func init() {
 	listCmd.AddCommand(listScenesCmd)
 	listScenesCmd.Flags().StringP("context", "c", "", "Defines the context to use")
 }
+
+func getScanOptions(cmd *cobra.Command) (client.ScanOptions, error) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	interfaceName, _ := cmd.Flags().GetString("interface")
+	ipv6, _ := cmd.Flags().GetBool("ipv6")
+	options := client.ScanOptions{
+		Timeout:     timeout,
+		DisableIPv6: !ipv6,
+		Logger:      logger,
+	}
+	if interfaceName != "" {
+		networkInterface, err := net.InterfaceByName(interfaceName)
+		if err != nil {
+			return options, fmt.Errorf("unknown network interface %s: %w", interfaceName, err)
+		}
+		options.Interface = networkInterface
+	}
+
+	return options, nil
+}
+
+func watchHubs(cmd *cobra.Command, options client.ScanOptions) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	output := cmd.Flag("output").Value.String()
+	if output != "text" && output != "json" && output != "yaml" {
+		return fmt.Errorf("unknown output format: %s", output)
+	}
+	fmt.Printf("Watching for hubs, stop with Ctrl-C...\n")
+	for event := range client.Browse(ctx, client.BrowseOptions{ScanOptions: options, Interval: interval}) {
+		_ = printOutput(cmd, event, func(writer io.Writer) {
+			_, _ = fmt.Fprintf(writer, "%s %s: %s (%s:%d, serial number %s, firmware %s)\n",
+				time.Now().Format(time.TimeOnly), event.Type, event.Hub.HostName, event.Hub.Address, event.Hub.Port,
+				event.Hub.SerialNumber, event.Hub.FirmwareVersion)
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// logger is used for the diagnostic output of the client and long-running commands. It is configured by the
+// flags --log-level and --log-format before a command runs.
+var logger = slog.New(slog.DiscardHandler)
+
+// initLogger creates the logger from the flags --log-level and --log-format, writing to stderr.
+func initLogger(cmd *cobra.Command) error {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	format, _ := cmd.Flags().GetString("log-format")
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return fmt.Errorf("invalid log level %s: must be debug, info, warn or error", levelName)
+	}
+	options := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+	default:
+		return fmt.Errorf("invalid log format %s: must be text or json", format)
+	}
+
+	return nil
+}
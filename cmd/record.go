@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/recorder"
+	"github.com/spf13/cobra"
+)
+
+// recordCmd represents the record command
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record the measured values of devices as time series",
+	Long: `Turns the state changes of devices into time-series points until stopped by Ctrl-C. Points are tagged with
+the room, name, ID and type of the device. With --snapshot (default) the current state of all devices is recorded
+at start and after the connection to the hub was lost.
+
+Formats:
+
+influx  InfluxDB line protocol, written to stdout (--output -), appended to a file or sent in batches to an HTTP
+        write endpoint (--output http://...). The token is sent as "Authorization: Token <token>".
+csv     One CSV file per day in the directory --output, with one row per field.
+parquet One Parquet file per day in the directory --output, with the rows of csv and the value in the column
+        value, bool_value or string_value depending on its type. A file is complete when the day ends or the
+        recorder stops, a restart on the same day writes another file of the day.
+
+Attributes are mapped to fields by default for sensors (e.g. currentTemperature as temperature, currentRH as
+humidity, currentPM25 as pm25, vocIndex as voc_index), outlets (power, current, voltage, energy), lights, blinds
+and batteries. Use --field to change the name of a field or - to skip an attribute.
+
+Examples:
+
+ikea record
+
+ikea record --output climate.lp --field currentTemperature=temp
+
+ikea record --output "http://influxdb:8086/api/v2/write?org=home&bucket=climate&precision=ns" --token $TOKEN
+
+ikea record --format csv --output /var/lib/ikea/records
+
+ikea record --format parquet --output /var/lib/ikea/records`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		fieldMapping, _ := cmd.Flags().GetStringToString("field")
+		measurement, _ := cmd.Flags().GetString("measurement")
+		allAttributes, _ := cmd.Flags().GetBool("all-attributes")
+		snapshot, _ := cmd.Flags().GetBool("snapshot")
+
+		fields := maps.Clone(recorder.DefaultFields)
+		for attribute, field := range fieldMapping {
+			if field == "-" {
+				delete(fields, attribute)
+			} else {
+				fields[attribute] = field
+			}
+		}
+		writer, err := getRecordWriter(cmd, format, output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writer.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: could not close output: %v\n", err)
+			}
+		}()
+
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		timeSeriesRecorder := recorder.New(getDirigeraClient(usedContext), writer, recorder.Options{
+			Measurement:   measurement,
+			Fields:        fields,
+			AllAttributes: allAttributes,
+			Snapshot:      snapshot,
+			Logger:        logger,
+		})
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// The line protocol on stdout must not be mixed with messages
+		if output != "-" {
+			fmt.Printf("Recording context %s to %s...\n", usedContextName, output)
+		}
+
+		return timeSeriesRecorder.Run(ctx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recordCmd)
+	recordCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	recordCmd.Flags().String("format", "influx", "Defines the format of the points (influx, csv or parquet)")
+	recordCmd.Flags().StringP("output", "o", "-", "Defines stdout (-), a file or an HTTP write endpoint for influx and the directory for csv and parquet")
+	recordCmd.Flags().String("token", "", "Defines the token for the HTTP write endpoint (default $IKEA_INFLUX_TOKEN)")
+	recordCmd.Flags().String("measurement", recorder.DefaultMeasurement, "Defines the measurement of the points")
+	recordCmd.Flags().StringToString("field", nil, "Maps an attribute to a field name, - skips the attribute (e.g. currentTemperature=temp)")
+	recordCmd.Flags().Bool("all-attributes", false, "Record all numeric and boolean attributes, not only the mapped ones")
+	recordCmd.Flags().Bool("snapshot", true, "Record the current state of all devices at start and after reconnects")
+	recordCmd.Flags().Int("batch-size", 1000, "Defines the maximum number of points sent to the HTTP write endpoint at once")
+	recordCmd.Flags().Duration("flush-interval", 10*time.Second, "Defines how often points are sent to the HTTP write endpoint")
+	recordCmd.Flags().String("prefix", "dirigera", "Defines the prefix of the CSV and Parquet file names")
+}
+
+func getRecordWriter(cmd *cobra.Command, format, output string) (recorder.Writer, error) {
+	switch format {
+	case "influx":
+		switch {
+		case output == "-":
+			return recorder.NewLineProtocolWriter(os.Stdout), nil
+		case strings.HasPrefix(output, "http://"), strings.HasPrefix(output, "https://"):
+			token, _ := cmd.Flags().GetString("token")
+			if token == "" {
+				token = os.Getenv("IKEA_INFLUX_TOKEN")
+			}
+			batchSize, _ := cmd.Flags().GetInt("batch-size")
+			flushInterval, _ := cmd.Flags().GetDuration("flush-interval")
+			if flushInterval <= 0 {
+				return nil, fmt.Errorf("invalid flush interval %s: must be positive", flushInterval)
+			}
+			return recorder.NewInfluxHTTPWriter(output, token, batchSize, flushInterval, logger), nil
+		default:
+			return recorder.OpenLineProtocolFile(output)
+		}
+	case "csv":
+		if output == "-" {
+			output = "."
+		}
+		prefix, _ := cmd.Flags().GetString("prefix")
+		return recorder.NewCSVWriter(output, prefix, time.Local)
+	case "parquet":
+		if output == "-" {
+			output = "."
+		}
+		prefix, _ := cmd.Flags().GetString("prefix")
+		return recorder.NewParquetWriter(output, prefix, time.Local)
+	default:
+		return nil, fmt.Errorf("invalid format %s: must be influx, csv or parquet", format)
+	}
+}
@@ -1,25 +1,39 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/salex-org/ikea-dirigera-client/pkg/client"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/zalando/go-keyring"
 	"gopkg.in/yaml.v3"
 )
 
 const appName = "ikea-dirigera-cli"
 
 type Context struct {
-	AccessToken string `mapstructure:"-" yaml:"-" json:"-"` // Wird aus dem Keyring gelesen
-	Address     string `mapstructure:"address" yaml:"address" json:"address"`
-	Port        int    `mapstructure:"port" yaml:"port" json:"port"`
-	Fingerprint string `mapstructure:"fingerprint" yaml:"fingerprint" json:"fingerprint"`
+	AccessToken     string `mapstructure:"-" yaml:"-" json:"-"` // Wird aus dem Credential Store gelesen
+	Address         string `mapstructure:"address" yaml:"address" json:"address"`
+	Port            int    `mapstructure:"port" yaml:"port" json:"port"`
+	Fingerprint     string `mapstructure:"fingerprint" yaml:"fingerprint" json:"fingerprint"`
+	SerialNumber    string `mapstructure:"serial_number" yaml:"serial_number,omitempty" json:"serial_number,omitempty"` // Wird für die Suche nach geänderten Adressen verwendet
+	CredentialStore string `mapstructure:"credential_store" yaml:"credential_store,omitempty" json:"credential_store,omitempty"`
+	// Werden zusätzlich zum Fingerprint akzeptiert, z.B. während das Zertifikat des Hubs erneuert wird
+	PinnedFingerprints []string `mapstructure:"pinned_fingerprints" yaml:"pinned_fingerprints,omitempty" json:"pinned_fingerprints,omitempty"`
+}
+
+func (c *Context) authorization() *client.Authorization {
+	return &client.Authorization{
+		AccessToken:        c.AccessToken,
+		TLSFingerprint:     c.Fingerprint,
+		PinnedFingerprints: c.PinnedFingerprints,
+	}
 }
 
 type Config struct {
@@ -31,10 +45,23 @@ var appConfig Config
 
 var cfgFile string
 
+// The context defined by the environment variables IKEA_ADDRESS, IKEA_PORT, IKEA_TOKEN and IKEA_FINGERPRINT is
+// kept separate from the config, so it is never written to the config file.
+var (
+	envContext      *Context
+	envContextName  string
+	envContextError error
+)
+
+const defaultEnvContextName = "env"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "ikea",
 	Short: "A CLI tool for using the API of an IKEA DIRIGERA Hub",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return initLogger(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -43,6 +70,10 @@ func Execute() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	err := rootCmd.Execute()
 	if err != nil {
+		var mismatch *client.FingerprintMismatchError
+		if errors.As(err, &mismatch) {
+			_, _ = fmt.Fprintf(os.Stderr, "The hub presents an unknown certificate, check and accept it with 'ikea context trust'\n")
+		}
 		os.Exit(1)
 	}
 }
@@ -50,6 +81,8 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ikea-dirigera-cli.yaml)")
+	rootCmd.PersistentFlags().String("log-level", "warn", "Defines the level of log messages written to stderr (debug, info, warn or error)")
+	rootCmd.PersistentFlags().String("log-format", "text", "Defines the format of log messages (text or json)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -68,8 +101,6 @@ func initConfig() {
 		viper.SetConfigName(".ikea-dirigera-cli")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
-
 	// If a config file is found, read it in.
 	_ = viper.ReadInConfig()
 
@@ -83,13 +114,70 @@ func initConfig() {
 		appConfig.Contexts = make(map[string]*Context)
 	}
 
-	// Read access tokens from keyring
-	for name, context := range appConfig.Contexts {
-		token, err := keyring.Get(appName, name)
-		if err == nil && token != "" {
-			context.AccessToken = token
+	initEnvContext()
+}
+
+// initEnvContext reads the context defined by environment variables. Validation errors are only reported when
+// the context is used, so commands without a context still work with an incomplete environment.
+func initEnvContext() {
+	if !client.EnvConfigured() {
+		return
+	}
+	envContextName = os.Getenv(client.EnvContext)
+	if envContextName == "" {
+		envContextName = defaultEnvContextName
+	}
+	// The context of the environment must not silently replace a context of the config with the same name
+	if _, configured := appConfig.Contexts[envContextName]; configured {
+		envContextError = fmt.Errorf("context %s is defined by environment variables and in the config file, set %s to another name or unset %s",
+			envContextName, client.EnvContext, client.EnvAddress)
+		return
+	}
+	config, err := client.LoadEnvConfig()
+	if err != nil {
+		envContextError = err
+		return
+	}
+	envContext = &Context{
+		AccessToken:        config.AccessToken,
+		Address:            config.Address,
+		Port:               config.Port,
+		Fingerprint:        config.Fingerprint,
+		PinnedFingerprints: config.PinnedFingerprints,
+		CredentialStore:    credentialStoreEnv,
+	}
+}
+
+// isEnvContext returns true if the name refers to the context defined by environment variables.
+func isEnvContext(contextName string) bool {
+	return envContextName != "" && contextName == envContextName
+}
+
+// unknownContextError explains why a context can not be found in the config.
+func unknownContextError(contextName string) error {
+	if isEnvContext(contextName) {
+		return fmt.Errorf("context %s is defined by environment variables and can not be changed by the CLI", contextName)
+	}
+
+	return fmt.Errorf("unknown context: %s", contextName)
+}
+
+// writeConfig persists the contexts and the current context to the config file, creating the file if necessary.
+func writeConfig() error {
+	viper.Set("contexts", appConfig.Contexts)
+	viper.Set("current_context", appConfig.CurrentContext)
+	err := viper.WriteConfig()
+	if err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			if err := viper.SafeWriteConfig(); err != nil {
+				return fmt.Errorf("error writing config: %w", err)
+			}
+		} else {
+			return fmt.Errorf("error writing config: %w", err)
 		}
 	}
+
+	return nil
 }
 
 func printOutput(cmd *cobra.Command, data any, printTextOutput func(writer io.Writer)) error {
@@ -110,25 +198,76 @@ func printOutput(cmd *cobra.Command, data any, printTextOutput func(writer io.Wr
 	return nil
 }
 
-func getDirigeraClient(context *Context) client.Client {
-	return client.Connect(context.Address, context.Port, &client.Authorization{
-		AccessToken:    context.AccessToken,
-		TLSFingerprint: context.Fingerprint,
-	})
+func getDirigeraClient(context *Context, options ...client.Option) client.Client {
+	// The logger is set first, so it can be overridden by the options of the caller
+	options = append([]client.Option{client.WithLogger(logger)}, options...)
+	return client.Connect(context.Address, context.Port, context.authorization(), append(options, client.WithRediscovery(context.SerialNumber, func(address string, port int) {
+		// Only contexts from the config are persisted, temporary copies are just updated
+		fmt.Fprintf(os.Stderr, "Hub %s moved from %s:%d to %s:%d, updating context\n", context.SerialNumber, context.Address, context.Port, address, port)
+		context.Address = address
+		context.Port = port
+		for _, configuredContext := range appConfig.Contexts {
+			if configuredContext == context {
+				if err := writeConfig(); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: could not update context: %v\n", err)
+				}
+				break
+			}
+		}
+	}))...)
 }
 
+// getContext returns the context to use. The context is selected by the flag --context, the context defined by
+// environment variables, the variable IKEA_CONTEXT or the current context of the config in this order.
 func getContext(cmd *cobra.Command) (*Context, string, error) {
 	contextName := cmd.Flag("context").Value.String()
+	source := "--context"
+	if contextName == "" {
+		contextName = os.Getenv(client.EnvContext)
+		source = client.EnvContext
+	}
+	if contextName == "" && envContextName != "" {
+		contextName = envContextName
+	}
 	if contextName == "" {
 		contextName = appConfig.CurrentContext
+		source = "current_context"
 	}
 	if contextName == "" {
-		return nil, contextName, fmt.Errorf("context not set")
+		return nil, contextName, fmt.Errorf("context not set: use --context, set %s or define the hub with %s, %s and %s",
+			client.EnvContext, client.EnvAddress, client.EnvToken, client.EnvFingerprint)
+	}
+	return resolveContext(contextName, source)
+}
+
+// resolveContext returns the context with the specified name, source describes where the name was taken from.
+func resolveContext(contextName, source string) (*Context, string, error) {
+	if isEnvContext(contextName) {
+		if envContextError != nil {
+			return nil, contextName, envContextError
+		}
+		return envContext, contextName, nil
 	}
 	context, found := appConfig.Contexts[contextName]
 	if !found {
-		return nil, contextName, fmt.Errorf("unknown context: %s", contextName)
+		return nil, contextName, fmt.Errorf("unknown context %s (from %s)", contextName, source)
+	}
+	if err := loadAccessToken(contextName, context); err != nil {
+		return nil, contextName, err
 	}
 
 	return context, contextName, nil
 }
+
+// askForConfirmation prints the question and returns true if the user answers with yes.
+func askForConfirmation(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
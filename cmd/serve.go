@@ -0,0 +1,211 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/gateway"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const serveShutdownTimeout = 5 * time.Second
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a simplified HTTP API of the IKEA DIRIGERA Hub with its own API keys",
+	Long: `Serves a simplified HTTP API below /api/v1 until stopped by Ctrl-C, so other applications can control devices
+without knowing the access token of the hub. The API lists devices, rooms and scenes, sets attributes of devices,
+triggers scenes and streams the events of the hub as server-sent events. The OpenAPI specification is served at
+/api/v1/openapi.yaml.
+
+Clients authenticate with API keys from the key file, sent as bearer token or in the header X-API-Key. Create keys
+with "ikea serve key". Keys have the scopes read, control (set attributes) and scenes (trigger scenes) and can be
+restricted to rooms. Every request is written as JSON line to the audit log.
+
+Examples:
+
+ikea serve key kitchen-panel --scope read --scope control --room Kitchen --keys keys.yaml
+
+ikea serve --keys keys.yaml --listen :8080 --audit-log audit.log
+
+curl -H "Authorization: Bearer ikg_..." http://localhost:8080/api/v1/devices
+
+curl -N -H "Authorization: Bearer ikg_..." http://localhost:8080/api/v1/events?type=deviceStateChanged`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyFile, _ := cmd.Flags().GetString("keys")
+		listen, _ := cmd.Flags().GetString("listen")
+		auditLog, _ := cmd.Flags().GetString("audit-log")
+		certFile, _ := cmd.Flags().GetString("tls-cert")
+		keyPEMFile, _ := cmd.Flags().GetString("tls-key")
+		if (certFile == "") != (keyPEMFile == "") {
+			return fmt.Errorf("--tls-cert and --tls-key must be specified together")
+		}
+		keys, err := gateway.LoadKeys(keyFile)
+		if err != nil {
+			return err
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+
+		var auditWriter io.Writer = os.Stdout
+		if auditLog != "-" {
+			file, err := os.OpenFile(auditLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if err != nil {
+				return fmt.Errorf("could not open audit log: %w", err)
+			}
+			defer file.Close()
+			auditWriter = file
+		}
+
+		dirigeraClient := getDirigeraClient(usedContext)
+		apiGateway, err := gateway.New(dirigeraClient, gateway.Options{
+			Keys:        keys,
+			AuditLogger: slog.New(slog.NewJSONHandler(auditWriter, nil)),
+			Logger:      logger,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		server := &http.Server{
+			Addr:              listen,
+			Handler:           apiGateway.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			// Event streams end when the gateway is stopped
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+
+		serving := make(chan error, 1)
+		go func() {
+			fmt.Printf("Serving API of context %s on %s%s with %d keys...\n", usedContextName, listen, gateway.BasePath, len(keys))
+			var err error
+			if certFile != "" {
+				err = server.ListenAndServeTLS(certFile, keyPEMFile)
+			} else {
+				err = server.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				serving <- err
+			}
+			close(serving)
+		}()
+		running := make(chan error, 1)
+		go func() {
+			running <- apiGateway.Run(ctx)
+		}()
+
+		select {
+		case err = <-serving:
+			stop()
+			<-running
+		case err = <-running:
+		}
+		shutdownContext, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownContext)
+
+		return err
+	},
+}
+
+// serveKeyCmd represents the serve key command
+var serveKeyCmd = &cobra.Command{
+	Use:   "key <name>",
+	Short: "Create an API key for the HTTP API",
+	Long: `Creates a random API key and adds its hash to the key file, or prints the entry for the key file if no key
+file is specified. The key itself is only shown once and not stored.
+
+Without --scope the key is read-only, without --room it grants access to all rooms. Rooms are specified by ID or
+name.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyFile, _ := cmd.Flags().GetString("keys")
+		scopeNames, _ := cmd.Flags().GetStringSlice("scope")
+		rooms, _ := cmd.Flags().GetStringSlice("room")
+		var scopes []gateway.Scope
+		for _, name := range scopeNames {
+			scopes = append(scopes, gateway.Scope(name))
+		}
+
+		secret, key, err := gateway.GenerateKey(args[0], scopes, rooms)
+		if err != nil {
+			return err
+		}
+		if keyFile == "" {
+			entry, err := yaml.Marshal(gateway.KeyFile{Keys: []gateway.APIKey{key}})
+			if err != nil {
+				return fmt.Errorf("could not encode key: %w", err)
+			}
+			fmt.Printf("Add the key to the key file:\n\n%s\n", entry)
+		} else if err := addAPIKey(keyFile, key); err != nil {
+			return err
+		} else {
+			fmt.Printf("Key %s added to %s\n", key.Name, keyFile)
+		}
+		fmt.Printf("API key (only shown once): %s\n", secret)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	serveCmd.Flags().String("keys", "", "Defines the YAML file with the API keys")
+	serveCmd.Flags().String("listen", ":8080", "Defines the address to serve the API on")
+	serveCmd.Flags().String("audit-log", "-", "Defines the file the audit log is appended to (- for stdout)")
+	serveCmd.Flags().String("tls-cert", "", "Defines a PEM file with the certificate to serve the API with HTTPS")
+	serveCmd.Flags().String("tls-key", "", "Defines a PEM file with the key of the certificate")
+	_ = serveCmd.MarkFlagRequired("keys")
+
+	serveCmd.AddCommand(serveKeyCmd)
+	serveKeyCmd.Flags().String("keys", "", "Defines the YAML file the key is added to")
+	serveKeyCmd.Flags().StringSlice("scope", nil, "Defines the scopes of the key (read, control or scenes)")
+	serveKeyCmd.Flags().StringSlice("room", nil, "Restricts the key to the rooms with the specified IDs or names")
+}
+
+// addAPIKey adds the key to the key file, which is created if it does not exist.
+func addAPIKey(path string, key gateway.APIKey) error {
+	var keyFile gateway.KeyFile
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read key file: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &keyFile); err != nil {
+		return fmt.Errorf("could not parse key file %s: %w", path, err)
+	}
+	if slices.ContainsFunc(keyFile.Keys, func(existing gateway.APIKey) bool { return existing.Name == key.Name }) {
+		return fmt.Errorf("key %s already exists in %s", key.Name, path)
+	}
+	keyFile.Keys = append(keyFile.Keys, key)
+	content, err = yaml.Marshal(keyFile)
+	if err != nil {
+		return fmt.Errorf("could not encode key file: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("could not write key file: %w", err)
+	}
+
+	return nil
+}
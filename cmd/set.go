@@ -4,10 +4,13 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // setCmd represents the set command
@@ -29,23 +32,294 @@ var setContextCmd = &cobra.Command{
 			return fmt.Errorf("context %s not found", newContextName)
 		}
 		appConfig.CurrentContext = newContextName
-		viper.Set("current_context", appConfig.CurrentContext)
-		err := viper.WriteConfig()
-		if err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				if err := viper.SafeWriteConfig(); err != nil {
-					return fmt.Errorf("error writing config: %w", err)
-				}
-			} else {
-				return fmt.Errorf("error writing config: %w", err)
+		if err := writeConfig(); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+// setDeviceCmd represents the set device command
+var setDeviceCmd = &cobra.Command{
+	Use:     "device <id> [<key>=<value>...]",
+	Aliases: []string{"dev", "d"},
+	Short:   "Set attributes of the device with the specified id",
+	Long: `Sets attributes of the device with the specified id. The values are converted to the type of the current value
+of the attribute and only attributes the device can receive are accepted. Alternatively the attributes can be
+specified as JSON object.
+
+Examples:
+
+ikea set device 13406ed7-6b67-461d-87d5-44c9dbed844e_1 isOn=true lightLevel=40
+
+ikea set device 13406ed7-6b67-461d-87d5-44c9dbed844e_1 customName="Desk Lamp"
+
+ikea set device 13406ed7-6b67-461d-87d5-44c9dbed844e_1 --json '{"colorHue": 120, "colorSaturation": 1}'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		jsonAttributes, _ := cmd.Flags().GetString("json")
+		if jsonAttributes == "" && len(args) < 2 {
+			return fmt.Errorf("no attributes specified")
+		}
+		if jsonAttributes != "" && len(args) > 1 {
+			return fmt.Errorf("attributes must be specified either as key=value pairs or with --json")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceID := args[0]
+		jsonAttributes, _ := cmd.Flags().GetString("json")
+		transition, _ := cmd.Flags().GetDuration("transition")
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+		device, err := dirigeraClient.GetDevice(deviceID)
+		if err != nil {
+			return fmt.Errorf("could not get device: %w", err)
+		}
+
+		var attributes map[string]interface{}
+		if jsonAttributes != "" {
+			attributes, err = parseAttributesJSON(device, jsonAttributes)
+		} else {
+			attributes, err = parseAttributeAssignments(device, args[1:])
+		}
+		if err != nil {
+			return err
+		}
+
+		err = dirigeraClient.SetDeviceAttributes(device.ID, attributes, transition)
+		if err != nil {
+			return fmt.Errorf("could not update device %s in %s: %w", describeDevice(device), usedContextName, err)
+		}
+		fmt.Printf("Updated %d attributes of device %s in %s\n", len(attributes), describeDevice(device), usedContextName)
+
+		return nil
+	},
+}
+
+// setHubCmd represents the set hub command
+var setHubCmd = &cobra.Command{
+	Use:     "hub",
+	Aliases: []string{"h"},
+	Short:   "Change settings of the IKEA DIRIGERA Hub",
+	Long: `Changes the name, timezone, location or status light of the IKEA DIRIGERA Hub. The location is used by the hub
+to calculate sunrise and sunset.
+
+Examples:
+
+ikea set hub --name "Home"
+
+ikea set hub --timezone Europe/Berlin --latitude 52.52 --longitude 13.40
+
+ikea set hub --status-light off`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.NoArgs(cmd, args); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("latitude") != cmd.Flags().Changed("longitude") {
+			return fmt.Errorf("latitude and longitude must be specified together")
+		}
+		if statusLight, _ := cmd.Flags().GetString("status-light"); statusLight != "" && statusLight != "on" && statusLight != "off" {
+			return fmt.Errorf("invalid status light %s: must be on or off", statusLight)
+		}
+		for _, flag := range []string{"name", "timezone", "latitude", "status-light"} {
+			if cmd.Flags().Changed(flag) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no setting specified")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+
+		if cmd.Flags().Changed("name") {
+			name, _ := cmd.Flags().GetString("name")
+			if err := dirigeraClient.SetHubName(name); err != nil {
+				return fmt.Errorf("could not set hub name in %s: %w", usedContextName, err)
 			}
+			fmt.Printf("Hub name set to %s in %s\n", name, usedContextName)
+		}
+		if cmd.Flags().Changed("timezone") {
+			timezone, _ := cmd.Flags().GetString("timezone")
+			if err := dirigeraClient.SetHubTimezone(timezone); err != nil {
+				return fmt.Errorf("could not set hub timezone in %s: %w", usedContextName, err)
+			}
+			fmt.Printf("Hub timezone set to %s in %s\n", timezone, usedContextName)
+		}
+		if cmd.Flags().Changed("latitude") {
+			latitude, _ := cmd.Flags().GetFloat64("latitude")
+			longitude, _ := cmd.Flags().GetFloat64("longitude")
+			if err := dirigeraClient.SetHubCoordinates(client.Coordinates{Latitude: latitude, Longitude: longitude}); err != nil {
+				return fmt.Errorf("could not set hub location in %s: %w", usedContextName, err)
+			}
+			fmt.Printf("Hub location set to %f, %f in %s\n", latitude, longitude, usedContextName)
+		}
+		if cmd.Flags().Changed("status-light") {
+			statusLight, _ := cmd.Flags().GetString("status-light")
+			if err := dirigeraClient.SetHubStatusLight(statusLight == "on"); err != nil {
+				return fmt.Errorf("could not set hub status light in %s: %w", usedContextName, err)
+			}
+			fmt.Printf("Hub status light switched %s in %s\n", statusLight, usedContextName)
 		}
 
 		return nil
 	},
 }
 
+// setUserNameCmd represents the set user-name command
+var setUserNameCmd = &cobra.Command{
+	Use:     "user-name <name>",
+	Aliases: []string{"un"},
+	Short:   "Set the name of the user of the current or specified context in the IKEA DIRIGERA Hub",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.TrimSpace(args[0])
+		if name == "" {
+			return fmt.Errorf("user name must not be empty")
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+		err = dirigeraClient.UpdateCurrentUser(name)
+		if err != nil {
+			return fmt.Errorf("could not set user name in %s: %w", usedContextName, err)
+		}
+		fmt.Printf("User name set to %s in %s\n", name, usedContextName)
+
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(setCmd)
 	setCmd.AddCommand(setContextCmd)
+
+	setCmd.AddCommand(setDeviceCmd)
+	setDeviceCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	setDeviceCmd.Flags().StringP("json", "j", "", "Defines the attributes to set as JSON object")
+	setDeviceCmd.Flags().DurationP("transition", "t", 0, "Defines the duration of the transition to the new state (e.g. 500ms or 2s)")
+
+	setCmd.AddCommand(setUserNameCmd)
+	setUserNameCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+
+	setCmd.AddCommand(setHubCmd)
+	setHubCmd.Flags().StringP("context", "c", "", "Defines the context to use")
+	setHubCmd.Flags().StringP("name", "n", "", "Defines the name of the hub")
+	setHubCmd.Flags().String("timezone", "", "Defines the timezone of the hub (e.g. Europe/Berlin)")
+	setHubCmd.Flags().Float64("latitude", 0, "Defines the latitude of the hub location")
+	setHubCmd.Flags().Float64("longitude", 0, "Defines the longitude of the hub location")
+	setHubCmd.Flags().String("status-light", "", "Switches the status light of the hub on or off")
+}
+
+// parseAttributeAssignments converts key=value pairs into attributes using the types of the current attribute values.
+func parseAttributeAssignments(device *client.Device, assignments []string) (map[string]interface{}, error) {
+	attributes := make(map[string]interface{}, len(assignments))
+	for _, assignment := range assignments {
+		name, rawValue, found := strings.Cut(assignment, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid attribute %q: must be specified as key=value", assignment)
+		}
+		if err := checkReceivableAttribute(device, name); err != nil {
+			return nil, err
+		}
+		value, err := coerceAttributeValue(name, rawValue, device.Attributes[name])
+		if err != nil {
+			return nil, err
+		}
+		attributes[name] = value
+	}
+
+	return attributes, nil
+}
+
+// parseAttributesJSON decodes a raw attribute object and checks the values against the current attribute types.
+func parseAttributesJSON(device *client.Device, rawAttributes string) (map[string]interface{}, error) {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal([]byte(rawAttributes), &attributes); err != nil {
+		return nil, fmt.Errorf("invalid JSON attributes: %w", err)
+	}
+	if len(attributes) == 0 {
+		return nil, fmt.Errorf("no attributes specified")
+	}
+	for name, value := range attributes {
+		if err := checkReceivableAttribute(device, name); err != nil {
+			return nil, err
+		}
+		current, hasCurrent := device.Attributes[name]
+		if hasCurrent && current != nil && value != nil && fmt.Sprintf("%T", current) != fmt.Sprintf("%T", value) {
+			return nil, fmt.Errorf("invalid value for attribute %s: expected %s", name, describeAttributeType(current))
+		}
+	}
+
+	return attributes, nil
+}
+
+func checkReceivableAttribute(device *client.Device, name string) error {
+	if !device.CanReceive(name) {
+		return fmt.Errorf("attribute %s can not be set on device %s (supported: %s)", name, describeDevice(device), strings.Join(device.Capabilities.CanReceive, ", "))
+	}
+
+	return nil
+}
+
+// coerceAttributeValue converts the raw value to the type of the current value of the attribute. Attributes without
+// a current value are decoded as JSON if possible and used as string otherwise.
+func coerceAttributeValue(name, rawValue string, current interface{}) (interface{}, error) {
+	switch current.(type) {
+	case bool:
+		value, err := strconv.ParseBool(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for attribute %s: expected %s", name, describeAttributeType(current))
+		}
+		return value, nil
+	case float64:
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for attribute %s: expected %s", name, describeAttributeType(current))
+		}
+		return value, nil
+	case string:
+		return rawValue, nil
+	case map[string]interface{}, []interface{}:
+		var value interface{}
+		if err := json.Unmarshal([]byte(rawValue), &value); err != nil || fmt.Sprintf("%T", value) != fmt.Sprintf("%T", current) {
+			return nil, fmt.Errorf("invalid value for attribute %s: expected %s", name, describeAttributeType(current))
+		}
+		return value, nil
+	default:
+		var value interface{}
+		if err := json.Unmarshal([]byte(rawValue), &value); err == nil {
+			return value, nil
+		}
+		return rawValue, nil
+	}
+}
+
+func describeAttributeType(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case map[string]interface{}:
+		return "a JSON object"
+	case []interface{}:
+		return "a JSON array"
+	default:
+		return fmt.Sprintf("a value of type %T", value)
+	}
 }
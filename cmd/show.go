@@ -163,6 +163,40 @@ var showSceneCmd = &cobra.Command{
 	},
 }
 
+// showHubCmd represents the show hub command
+var showHubCmd = &cobra.Command{
+	Use:     "hub",
+	Aliases: []string{"h"},
+	Short:   "Show details and settings of the IKEA DIRIGERA Hub",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+		hub, err := dirigeraClient.GetHub()
+		if err != nil {
+			return fmt.Errorf("could not get hub: %w", err)
+		}
+		return printOutput(cmd, hub, func(writer io.Writer) {
+			statusLight := "off"
+			if hub.StatusLightOn {
+				statusLight = "on"
+			}
+			coordinates := "not set"
+			if hub.Coordinates != nil {
+				coordinates = fmt.Sprintf("%f, %f", hub.Coordinates.Latitude, hub.Coordinates.Longitude)
+			}
+			_, _ = fmt.Fprintf(writer, "using context: %s\n", usedContextName)
+			_, _ = fmt.Fprintf(writer, "ID: %s\nname: %s\nmodel: %s\nserial number: %s\n", hub.ID, hub.Name, hub.Model, hub.SerialNumber)
+			_, _ = fmt.Fprintf(writer, "firmware: %s\nhardware: %s\n", hub.FirmwareVersion, hub.HardwareVersion)
+			_, _ = fmt.Fprintf(writer, "country code: %s\ntimezone: %s\ncoordinates: %s\nstatus light: %s\n", hub.CountryCode, hub.Timezone, coordinates, statusLight)
+			_, _ = fmt.Fprintf(writer, "is reachable: %t\ncreated at: %s\nlast seen: %s\n", hub.IsReachable, hub.CreatedAt, hub.LastSeen)
+		})
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(showCmd)
 	showCmd.PersistentFlags().StringP("context", "c", "", "Defines the context to use")
@@ -180,4 +214,7 @@ func init() {
 
 	showCmd.AddCommand(showSceneCmd)
 	showSceneCmd.Flags().StringP("output", "o", "text", "Defines the format of the output (text or json)")
+
+	showCmd.AddCommand(showHubCmd)
+	showHubCmd.Flags().StringP("output", "o", "text", "Defines the format of the output (text or json)")
 }
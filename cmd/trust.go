@@ -0,0 +1,218 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+const certificateTimeout = 10 * time.Second
+
+// contextTrustCmd represents the context trust command
+var contextTrustCmd = &cobra.Command{
+	Use:   "trust [<name>]",
+	Short: "Show the certificate of the hub and trust it for a context",
+	Long: `Connects to the hub of the current or specified context, shows the presented certificate and compares its
+SHA-256 fingerprint with the fingerprints trusted by the context. If the hub presents a new certificate, e.g. after
+a factory reset, the new fingerprint can be accepted instead of authorizing again. Compare the fingerprint with a
+trusted source before accepting it, or pass the expected fingerprint with --fingerprint.
+
+With --keep-old the previous fingerprint stays pinned, so the context keeps working during the rotation of the
+certificate. Pinned fingerprints are removed with 'ikea context unpin'.
+
+Examples:
+
+ikea context trust
+
+ikea context trust my-context --fingerprint AB:CD:...:EF
+
+ikea context trust my-context --keep-old --yes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		expectedFingerprint, _ := cmd.Flags().GetString("fingerprint")
+		keepOld, _ := cmd.Flags().GetBool("keep-old")
+		skipConfirmation, _ := cmd.Flags().GetBool("yes")
+		contextName := appConfig.CurrentContext
+		if len(args) > 0 {
+			contextName = args[0]
+		}
+		if contextName == "" {
+			return fmt.Errorf("context not set")
+		}
+		configuredContext, found := appConfig.Contexts[contextName]
+		if !found {
+			return unknownContextError(contextName)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), certificateTimeout)
+		defer cancel()
+		certificate, err := client.FetchCertificate(ctx, configuredContext.Address, configuredContext.Port)
+		if err != nil {
+			return fmt.Errorf("could not get certificate of %s:%d: %w", configuredContext.Address, configuredContext.Port, err)
+		}
+
+		fmt.Printf("certificate presented by %s:%d:\n", configuredContext.Address, configuredContext.Port)
+		fmt.Printf("subject: %s\nissuer: %s\nserial number: %s\n", certificate.Subject, certificate.Issuer, certificate.SerialNumber)
+		fmt.Printf("valid: %s - %s\n", certificate.NotBefore.Format(time.DateTime), certificate.NotAfter.Format(time.DateTime))
+		fmt.Printf("SHA-256: %s\n", formatFingerprint(certificate.Fingerprint))
+		fmt.Printf("stored fingerprint: %s\n", formatFingerprint(configuredContext.Fingerprint))
+		for _, pinned := range configuredContext.PinnedFingerprints {
+			fmt.Printf("pinned fingerprint: %s\n", formatFingerprint(pinned))
+		}
+		if certificate.Expired(time.Now()) {
+			fmt.Printf("warning: the certificate is not valid at the current time\n")
+		}
+
+		switch {
+		case certificate.Fingerprint == configuredContext.Fingerprint:
+			fmt.Printf("The certificate is already trusted by context %s\n", contextName)
+			return nil
+		case configuredContext.authorization().Trusts(certificate.Fingerprint):
+			fmt.Printf("The certificate matches a pinned fingerprint of context %s\n", contextName)
+		default:
+			fmt.Printf("The certificate is NOT trusted by context %s\n", contextName)
+		}
+
+		if expectedFingerprint != "" {
+			expected := client.NewAuthorization("", expectedFingerprint)
+			if !expected.Trusts(certificate.Fingerprint) {
+				return fmt.Errorf("the presented certificate does not match the expected fingerprint %s", expectedFingerprint)
+			}
+		} else if !skipConfirmation && !askForConfirmation(fmt.Sprintf("Trust the certificate for context %s?", contextName)) {
+			fmt.Printf("Context %s not changed\n", contextName)
+			return nil
+		}
+
+		return updateContext(cmd, contextName, func(context *Context) {
+			previousFingerprint := context.Fingerprint
+			context.Fingerprint = certificate.Fingerprint
+			context.PinnedFingerprints = slices.DeleteFunc(slices.Clone(context.PinnedFingerprints), func(pinned string) bool {
+				return pinned == certificate.Fingerprint
+			})
+			if keepOld && previousFingerprint != "" {
+				context.PinnedFingerprints = append(context.PinnedFingerprints, previousFingerprint)
+			}
+		})
+	},
+}
+
+// contextPinCmd represents the context pin command
+var contextPinCmd = &cobra.Command{
+	Use:   "pin <name> <fingerprint>",
+	Short: "Trust an additional TLS fingerprint for a context",
+	Long: `Pins an additional SHA-256 fingerprint for a context, e.g. the fingerprint of a certificate that will be
+installed on the hub soon. Both the stored and the pinned fingerprints are accepted when connecting to the hub.
+
+Examples:
+
+ikea context pin my-context AB:CD:...:EF`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if _, err := parseFingerprint(args[1]); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fingerprint, _ := parseFingerprint(args[1])
+		return updateContext(cmd, args[0], func(context *Context) {
+			if fingerprint != context.Fingerprint && !slices.Contains(context.PinnedFingerprints, fingerprint) {
+				context.PinnedFingerprints = append(slices.Clone(context.PinnedFingerprints), fingerprint)
+			}
+		})
+	},
+}
+
+// contextUnpinCmd represents the context unpin command
+var contextUnpinCmd = &cobra.Command{
+	Use:   "unpin <name> [<fingerprint>]",
+	Short: "Remove pinned TLS fingerprints from a context",
+	Long: `Removes a pinned fingerprint or all pinned fingerprints with --all from a context, e.g. after the rotation of
+the hub certificate is completed. The stored fingerprint of the context is not changed.
+
+Examples:
+
+ikea context unpin my-context --all`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return fmt.Errorf("specify either a fingerprint or --all")
+		}
+		if _, err := parseFingerprint(args[1]); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		var fingerprint string
+		if !all {
+			fingerprint, _ = parseFingerprint(args[1])
+			context, found := appConfig.Contexts[args[0]]
+			if !found {
+				return unknownContextError(args[0])
+			}
+			if !slices.Contains(context.PinnedFingerprints, fingerprint) {
+				return fmt.Errorf("fingerprint %s is not pinned in context %s", args[1], args[0])
+			}
+		}
+		return updateContext(cmd, args[0], func(context *Context) {
+			if all {
+				context.PinnedFingerprints = nil
+				return
+			}
+			context.PinnedFingerprints = slices.DeleteFunc(slices.Clone(context.PinnedFingerprints), func(pinned string) bool {
+				return pinned == fingerprint
+			})
+		})
+	},
+}
+
+func init() {
+	contextCmd.AddCommand(contextTrustCmd)
+	contextTrustCmd.Flags().String("fingerprint", "", "Only trust the certificate if it matches the expected fingerprint")
+	contextTrustCmd.Flags().Bool("keep-old", false, "Keep the previous fingerprint pinned during the rotation of the certificate")
+	contextTrustCmd.Flags().BoolP("yes", "y", false, "Trust the certificate without asking for confirmation")
+
+	contextCmd.AddCommand(contextPinCmd)
+
+	contextCmd.AddCommand(contextUnpinCmd)
+	contextUnpinCmd.Flags().Bool("all", false, "Remove all pinned fingerprints")
+}
+
+// parseFingerprint accepts SHA-256 fingerprints in hex with or without colons, like the output of openssl.
+func parseFingerprint(value string) (string, error) {
+	fingerprint := client.NewAuthorization("", value).TLSFingerprint
+	if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("invalid fingerprint %s: must be a SHA-256 fingerprint with 64 hex digits", value)
+	}
+
+	return fingerprint, nil
+}
+
+// formatFingerprint formats a fingerprint with colons like openssl, e.g. AB:CD:EF.
+func formatFingerprint(fingerprint string) string {
+	if fingerprint == "" {
+		return "none"
+	}
+	var parts []string
+	for index := 0; index+2 <= len(fingerprint); index += 2 {
+		parts = append(parts, strings.ToUpper(fingerprint[index:index+2]))
+	}
+
+	return strings.Join(parts, ":")
+}
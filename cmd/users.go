@@ -0,0 +1,217 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// usersCmd represents the users command
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Maintain the users of the IKEA DIRIGERA Hub",
+}
+
+// usersPruneCmd represents the users prune command
+var usersPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove stale users from the IKEA DIRIGERA Hub",
+	Long: `Removes users created by "ikea authorize" from the IKEA DIRIGERA Hub that are not used by any context of the
+CLI. The user of the used context and the users of all other contexts for the same hub are always kept. Only users
+named <user>@<host> like the default name of "ikea authorize" are selected, other users like the ones of the IKEA
+Home smart app are only selected with --include-foreign. The filters --older-than and --name restrict the removal
+to users matching all specified filters. The planned changes are shown and must be confirmed before any user is
+removed, --yes requires at least one filter.
+
+Examples:
+
+ikea users prune --dry-run
+
+ikea users prune --older-than 90d
+
+ikea users prune --name "*@build-*" --yes
+
+ikea users prune --name "Old tablet" --include-foreign`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.NoArgs(cmd, args); err != nil {
+			return err
+		}
+		if olderThan, _ := cmd.Flags().GetString("older-than"); olderThan != "" {
+			if _, err := parseAge(olderThan); err != nil {
+				return err
+			}
+		}
+		if pattern, _ := cmd.Flags().GetString("name"); pattern != "" {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid name pattern %s: %w", pattern, err)
+			}
+		}
+		skipConfirmation, _ := cmd.Flags().GetBool("yes")
+		if skipConfirmation && !cmd.Flags().Changed("older-than") && !cmd.Flags().Changed("name") {
+			return fmt.Errorf("--yes requires --older-than or --name")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		pattern, _ := cmd.Flags().GetString("name")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		skipConfirmation, _ := cmd.Flags().GetBool("yes")
+		includeForeign, _ := cmd.Flags().GetBool("include-foreign")
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dirigeraClient := getDirigeraClient(usedContext)
+
+		users, err := dirigeraClient.ListUsers()
+		if err != nil {
+			return fmt.Errorf("could not list users: %w", err)
+		}
+		currentUser, err := dirigeraClient.GetCurrentUser()
+		if err != nil {
+			return fmt.Errorf("could not get current user: %w", err)
+		}
+		referencedUsers := findReferencedUsers(usedContext, usedContextName)
+
+		var cutoff time.Time
+		if olderThan != "" {
+			age, _ := parseAge(olderThan)
+			cutoff = time.Now().Add(-age)
+		}
+
+		var usersToDelete []*client.User
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"ID", "Name", "Created At", "Action", "Reason"})
+		for _, user := range users {
+			action, reason := "delete", "not used by any context"
+			switch {
+			case user.ID == currentUser.ID:
+				action, reason = "keep", "user of context "+usedContextName
+			case referencedUsers[user.ID] != "":
+				action, reason = "keep", "user of context "+referencedUsers[user.ID]
+			case !includeForeign && !isCLIUserName(user.Name):
+				action, reason = "keep", "not created by the CLI"
+			case !cutoff.IsZero() && !user.CreatedAt.Before(cutoff):
+				action, reason = "keep", "created after "+cutoff.Format(time.DateOnly)
+			case pattern != "" && !matchesUserName(pattern, user.Name):
+				action, reason = "keep", "name does not match "+pattern
+			}
+			if action == "delete" {
+				usersToDelete = append(usersToDelete, user)
+			}
+			t.AppendRow(table.Row{
+				user.ID, user.Name, user.CreatedAt.Format(time.DateTime), action, reason,
+			})
+		}
+		t.SetStyle(table.StyleDefault)
+		t.SetAutoIndex(true)
+		fmt.Printf("using context: %s\n", usedContextName)
+		fmt.Printf("found %d users, %d will be removed:\n", len(users), len(usersToDelete))
+		t.Render()
+
+		if len(usersToDelete) == 0 || dryRun {
+			return nil
+		}
+		if !skipConfirmation && !askForConfirmation(fmt.Sprintf("Remove %d users from the hub?", len(usersToDelete))) {
+			fmt.Printf("No users removed\n")
+			return nil
+		}
+
+		failed := 0
+		for _, user := range usersToDelete {
+			if err := dirigeraClient.DeleteUser(user.ID); err != nil {
+				fmt.Printf("warning: could not delete user %s (%s): %v\n", user.ID, user.Name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Deleted user %s (%s)\n", user.ID, user.Name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("could not delete %d of %d users", failed, len(usersToDelete))
+		}
+		fmt.Printf("Removed %d users in %s\n", len(usersToDelete), usedContextName)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(usersCmd)
+	usersCmd.PersistentFlags().StringP("context", "c", "", "Defines the context to use")
+
+	usersCmd.AddCommand(usersPruneCmd)
+	usersPruneCmd.Flags().String("older-than", "", "Only remove users created before the specified age (e.g. 90d or 72h)")
+	usersPruneCmd.Flags().StringP("name", "n", "", "Only remove users with a name matching the specified pattern (e.g. \"*@build-*\")")
+	usersPruneCmd.Flags().Bool("dry-run", false, "Only show the planned changes without removing any user")
+	usersPruneCmd.Flags().BoolP("yes", "y", false, "Remove the users without asking for confirmation")
+	usersPruneCmd.Flags().Bool("include-foreign", false, "Also remove users not named like the users created by the CLI, e.g. of the IKEA Home smart app")
+}
+
+// findReferencedUsers returns the IDs of the users used by contexts for the same hub mapped to the context names.
+// Contexts for the same hub are identified by the TLS fingerprint of the hub.
+func findReferencedUsers(usedContext *Context, usedContextName string) map[string]string {
+	referencedUsers := make(map[string]string)
+	for name, context := range appConfig.Contexts {
+		if name == usedContextName || context.Fingerprint != usedContext.Fingerprint {
+			continue
+		}
+		if err := loadAccessToken(name, context); err != nil {
+			fmt.Printf("warning: could not get user of context %s: %v\n", name, err)
+			continue
+		}
+		user, err := getDirigeraClient(context).GetCurrentUser()
+		if err != nil {
+			fmt.Printf("warning: could not get user of context %s: %v\n", name, err)
+			continue
+		}
+		referencedUsers[user.ID] = name
+	}
+
+	return referencedUsers
+}
+
+// isCLIUserName returns true if the name follows the default user name <user>@<host> of "ikea authorize" or is the
+// UUID used if the user or host is unknown. Hosts are named without domain, so e-mail addresses do not match.
+func isCLIUserName(name string) bool {
+	if err := uuid.Validate(name); err == nil {
+		return true
+	}
+	userName, host, found := strings.Cut(name, "@")
+
+	return found && userName != "" && host != "" && !strings.ContainsAny(host, "@. ") && !strings.ContainsRune(userName, ' ')
+}
+
+func matchesUserName(pattern, name string) bool {
+	matches, _ := path.Match(pattern, name)
+	return matches
+}
+
+// parseAge parses a duration that additionally supports days with the suffix d (e.g. 30d).
+func parseAge(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err != nil || count < 0 {
+			return 0, fmt.Errorf("invalid age %s: must be a number of days (e.g. 30d) or a duration (e.g. 72h)", value)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %s: must be a number of days (e.g. 30d) or a duration (e.g. 72h)", value)
+	}
+
+	return age, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/webhooks"
+	"github.com/spf13/cobra"
+)
+
+// webhooksCmd represents the webhooks command
+var webhooksCmd = &cobra.Command{
+	Use:     "webhooks",
+	Aliases: []string{"webhook"},
+	Short:   "Send events of the IKEA DIRIGERA Hub to webhooks",
+}
+
+// webhooksRunCmd represents the webhooks run command
+var webhooksRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Post matching events to the webhooks defined in a file",
+	Long: `Posts the events of the hub matching the filter of a webhook to its URL until stopped by Ctrl-C. Failed
+deliveries are retried with exponential backoff and kept in the queue directory, so they are sent after a restart.
+
+Payloads are JSON by default or formatted for Slack, Discord or ntfy. A Go template can define any other payload
+with the fields Webhook, ID, Type, Time, DeviceID, Device, DeviceType, Room, Attributes (changed attributes),
+State (all attributes) and Summary. If a secret is defined, the header X-Dirigera-Signature contains
+sha256=<HMAC-SHA256 of "<X-Dirigera-Timestamp>.<body>">.
+
+Example webhooks.yaml:
+
+queue: /var/lib/ikea/webhooks
+webhooks:
+  - name: motion
+    url: ${SLACK_WEBHOOK_URL}
+    format: slack
+    filter:
+      types: [deviceStateChanged]
+      rooms: [Hallway]
+      attributes: [isDetected]
+      expression: '{{ .Attributes.isDetected }}'
+  - name: too-warm
+    url: https://ntfy.sh/my-home
+    format: ntfy
+    headers:
+      Title: Temperature alert
+    filter:
+      devices: [Bedroom sensor]
+      expression: '{{ gt .State.currentTemperature 25.0 }}'
+  - name: automation
+    url: https://example.com/hooks/dirigera
+    secret: ${WEBHOOK_SECRET}
+    template: '{"device":{{ json .Device }},"on":{{ json .State.isOn }}}'
+    retry:
+      max_attempts: 10
+      initial_backoff: 5s
+      max_backoff: 1h`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		config, err := webhooks.LoadConfig(file)
+		if err != nil {
+			return err
+		}
+		usedContext, usedContextName, err := getContext(cmd)
+		if err != nil {
+			return fmt.Errorf("could not get context: %w", err)
+		}
+		dispatcher, err := webhooks.New(getDirigeraClient(usedContext), config, logger)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("Sending events of context %s to %d webhooks...\n", usedContextName, len(config.Webhooks))
+		if err := dispatcher.Run(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Webhooks stopped")
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(webhooksCmd)
+	webhooksCmd.PersistentFlags().StringP("context", "c", "", "Defines the context to use")
+
+	webhooksCmd.AddCommand(webhooksRunCmd)
+	webhooksRunCmd.Flags().StringP("file", "f", "webhooks.yaml", "Defines the YAML file with the webhooks")
+}
@@ -0,0 +1,185 @@
+package automation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"github.com/salex-org/ikea-dirigera-client/pkg/webhooks"
+)
+
+const requestTimeout = 10 * time.Second
+
+func newTemplate(name, source string) (*template.Template, error) {
+	return template.New(name).Funcs(webhooks.TemplateFunctions).Option("missingkey=zero").Parse(source)
+}
+
+func (e *Engine) runAction(ctx context.Context, runner *ruleRunner, action *Action, data Data) error {
+	switch {
+	case len(action.Set) > 0:
+		return e.setAttributes(runner, action)
+	case action.Scene != "":
+		return e.triggerScene(runner, action.Scene)
+	case action.Webhook != "":
+		return e.callWebhook(ctx, runner, action, data)
+	case action.Delay > 0:
+		runner.logger.Info("waiting", "delay", action.Delay)
+		timer := time.NewTimer(action.Delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	default:
+		return e.notify(ctx, runner, action, data)
+	}
+}
+
+// setAttributes changes the attributes of the selected devices. Devices in rooms only receive the attributes they
+// support, devices selected by ID or name receive all.
+func (e *Engine) setAttributes(runner *ruleRunner, action *Action) error {
+	targets := make(map[string]map[string]interface{})
+	var errs []error
+	for _, selector := range action.Devices {
+		device := e.devices.Find(selector)
+		if device == nil {
+			errs = append(errs, fmt.Errorf("device %s not found", selector))
+			continue
+		}
+		targets[device.ID] = action.Set
+	}
+	if len(action.Rooms) > 0 {
+		for _, device := range e.devices.Devices() {
+			if _, selected := targets[device.ID]; selected || !selects(nil, action.Rooms, device.ID, device) {
+				continue
+			}
+			supported := maps.Clone(action.Set)
+			maps.DeleteFunc(supported, func(attribute string, _ interface{}) bool {
+				return !device.CanReceive(attribute)
+			})
+			if len(supported) > 0 {
+				targets[device.ID] = supported
+			}
+		}
+	}
+
+	for _, deviceID := range slices.Sorted(maps.Keys(targets)) {
+		attributes := targets[deviceID]
+		if e.options.DryRun {
+			runner.logger.Info("would set attributes", "device", deviceID, "attributes", attributes)
+			continue
+		}
+		if err := e.dirigeraClient.SetDeviceAttributes(deviceID, attributes, action.Transition); err != nil {
+			errs = append(errs, fmt.Errorf("could not set attributes of device %s: %w", deviceID, err))
+			continue
+		}
+		runner.logger.Info("set attributes", "device", deviceID, "attributes", attributes)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e *Engine) triggerScene(runner *ruleRunner, selector string) error {
+	scenes, err := e.dirigeraClient.ListScenes()
+	if err != nil {
+		return fmt.Errorf("could not list scenes: %w", err)
+	}
+	index := slices.IndexFunc(scenes, func(scene *client.Scene) bool {
+		return scene.ID == selector || strings.EqualFold(scene.Info.Name, selector)
+	})
+	if index < 0 {
+		return fmt.Errorf("scene %s not found", selector)
+	}
+	scene := scenes[index]
+
+	if e.options.DryRun {
+		runner.logger.Info("would trigger scene", "scene", scene.Info.Name, "scene_id", scene.ID)
+		return nil
+	}
+	if err := e.dirigeraClient.TriggerScene(scene.ID); err != nil {
+		return fmt.Errorf("could not trigger scene %s: %w", scene.Info.Name, err)
+	}
+	runner.logger.Info("triggered scene", "scene", scene.Info.Name, "scene_id", scene.ID)
+
+	return nil
+}
+
+func (e *Engine) callWebhook(ctx context.Context, runner *ruleRunner, action *Action, data Data) error {
+	var body bytes.Buffer
+	if err := action.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("could not render body: %w", err)
+	}
+	if e.options.DryRun {
+		runner.logger.Info("would call webhook", "url", action.Webhook, "body", body.String())
+		return nil
+	}
+	if err := e.post(ctx, action.Webhook, action.ContentType, action.Headers, body.Bytes()); err != nil {
+		return fmt.Errorf("could not call webhook: %w", err)
+	}
+	runner.logger.Info("called webhook", "url", action.Webhook)
+
+	return nil
+}
+
+// notify sends the message to the notify URL in its format. Without URL the message is only logged.
+func (e *Engine) notify(ctx context.Context, runner *ruleRunner, action *Action, data Data) error {
+	var message strings.Builder
+	if err := action.message.Execute(&message, data); err != nil {
+		return fmt.Errorf("could not render notify message: %w", err)
+	}
+	settings := e.config.Notify
+	if settings.URL == "" {
+		runner.logger.Info("notification", "message", message.String())
+		return nil
+	}
+	if e.options.DryRun {
+		runner.logger.Info("would notify", "message", message.String())
+		return nil
+	}
+
+	contentType, body, err := webhooks.EncodeMessage(settings.Format, message.String(), map[string]interface{}{"rule": data.Rule})
+	if err != nil {
+		return fmt.Errorf("could not encode notification: %w", err)
+	}
+	if err := e.post(ctx, settings.URL, contentType, settings.Headers, body); err != nil {
+		return fmt.Errorf("could not notify: %w", err)
+	}
+	runner.logger.Info("notified", "message", message.String())
+
+	return nil
+}
+
+func (e *Engine) post(ctx context.Context, url, contentType string, headers map[string]string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+
+	request, err := webhooks.NewRequest(ctx, url, header, body)
+	if err != nil {
+		return err
+	}
+
+	response, err := webhooks.Send(e.httpClient, request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("received status code %d", response.StatusCode)
+	}
+
+	return nil
+}
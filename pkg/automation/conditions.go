@@ -0,0 +1,160 @@
+package automation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// unmetCondition returns the description of the first condition of the rule that is not met, or an empty string
+// if all are met.
+func (e *Engine) unmetCondition(rule *Rule, now time.Time) string {
+	for index := range rule.Conditions {
+		condition := &rule.Conditions[index]
+		var met bool
+		switch {
+		case condition.Device != "":
+			met = e.deviceMatches(condition)
+		case condition.Room != "":
+			met = e.roomOccupied(condition.Room, condition.Within, now) == (condition.Occupied == nil || *condition.Occupied)
+		default:
+			met = e.inTimeWindow(condition, now)
+		}
+		if !met {
+			return condition.describe()
+		}
+	}
+
+	return ""
+}
+
+func (e *Engine) deviceMatches(condition *Condition) bool {
+	device := e.devices.Find(condition.Device)
+	if device == nil {
+		return false
+	}
+	value, known := device.Attributes[condition.Attribute]
+	if !known {
+		return false
+	}
+	if condition.Is != nil {
+		return equalValues(value, condition.Is)
+	}
+
+	return inRange(value, condition.Above, condition.Below)
+}
+
+// roomOccupied returns true if a sensor in the room detects someone or detected someone within the duration.
+func (e *Engine) roomOccupied(room string, within time.Duration, now time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, device := range e.devices.Devices() {
+		if room != device.Room.ID && !strings.EqualFold(room, device.Room.Name) {
+			continue
+		}
+		if detected, _ := device.Attributes["isDetected"].(bool); detected {
+			return true
+		}
+		if last, known := e.detected[device.ID]; known && within > 0 && now.Sub(last) <= within {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inTimeWindow returns true if now is on one of the weekdays and between after and before.
+func (e *Engine) inTimeWindow(condition *Condition, now time.Time) bool {
+	now = now.In(e.location)
+	if len(condition.weekdays) > 0 && !slices.Contains(condition.weekdays, now.Weekday()) {
+		return false
+	}
+	after, afterFound := e.resolve(condition.after, now)
+	before, beforeFound := e.resolve(condition.before, now)
+	if (condition.after.defined && !afterFound) || (condition.before.defined && !beforeFound) {
+		return false
+	}
+
+	switch {
+	case condition.after.defined && condition.before.defined && after.After(before):
+		// The window spans midnight
+		return !now.Before(after) || now.Before(before)
+	case condition.after.defined && condition.before.defined:
+		return !now.Before(after) && now.Before(before)
+	case condition.after.defined:
+		return !now.Before(after)
+	case condition.before.defined:
+		return now.Before(before)
+	default:
+		return true
+	}
+}
+
+// describe returns a description of the condition for logs.
+func (c *Condition) describe() string {
+	switch {
+	case c.Device != "" && c.Is != nil:
+		return fmt.Sprintf("%s of %s is %v", c.Attribute, c.Device, c.Is)
+	case c.Device != "":
+		return fmt.Sprintf("%s of %s%s", c.Attribute, c.Device, describeRange(c.Above, c.Below))
+	case c.Room != "" && c.Occupied != nil && !*c.Occupied:
+		return c.Room + " not occupied"
+	case c.Room != "":
+		return c.Room + " occupied"
+	}
+
+	var parts []string
+	if c.After != "" {
+		parts = append(parts, "after "+c.After)
+	}
+	if c.Before != "" {
+		parts = append(parts, "before "+c.Before)
+	}
+	if len(c.Weekdays) > 0 {
+		parts = append(parts, "on "+strings.Join(c.Weekdays, ", "))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func describeRange(above, below *float64) string {
+	description := ""
+	if above != nil {
+		description += fmt.Sprintf(" above %v", *above)
+	}
+	if below != nil {
+		description += fmt.Sprintf(" below %v", *below)
+	}
+
+	return description
+}
+
+// equalValues compares an attribute with a value of the rule file. Numbers are compared by value, strings
+// case-insensitive.
+func equalValues(value, expected interface{}) bool {
+	if number, isNumber := toFloat(value); isNumber {
+		expectedNumber, expectedIsNumber := toFloat(expected)
+		return expectedIsNumber && number == expectedNumber
+	}
+
+	return strings.EqualFold(fmt.Sprint(value), fmt.Sprint(expected))
+}
+
+// inRange returns true if the value is a number above and below the defined limits.
+func inRange(value interface{}, above, below *float64) bool {
+	number, isNumber := toFloat(value)
+
+	return isNumber && (above == nil || number > *above) && (below == nil || number < *below)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
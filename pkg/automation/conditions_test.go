@@ -0,0 +1,77 @@
+package automation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+var (
+	testLocation = time.FixedZone("CET", 3600)
+	berlin       = &client.Coordinates{Latitude: 52.52, Longitude: 13.405}
+	tromso       = &client.Coordinates{Latitude: 69.65, Longitude: 18.96}
+)
+
+func mustParseClockTime(t *testing.T, value string) clockTime {
+	t.Helper()
+	clock, err := parseClockTime(value)
+	if err != nil {
+		t.Fatalf("invalid time %s: %v", value, err)
+	}
+
+	return clock
+}
+
+func TestInTimeWindow(t *testing.T) {
+	// Monday, sunset in Berlin is at 15:53
+	day := func(hour, minute int) time.Time {
+		return time.Date(2025, time.December, 22, hour, minute, 0, 0, testLocation)
+	}
+	tests := []struct {
+		name        string
+		after       string
+		before      string
+		weekdays    []time.Weekday
+		coordinates *client.Coordinates
+		now         time.Time
+		expected    bool
+	}{
+		{name: "no window", now: day(12, 0), expected: true},
+		{name: "inside", after: "08:00", before: "17:00", now: day(12, 0), expected: true},
+		{name: "after is inclusive", after: "08:00", before: "17:00", now: day(8, 0), expected: true},
+		{name: "before is exclusive", after: "08:00", before: "17:00", now: day(17, 0), expected: false},
+		{name: "too early", after: "08:00", before: "17:00", now: day(7, 59), expected: false},
+		{name: "across midnight in the evening", after: "22:00", before: "06:00", now: day(23, 30), expected: true},
+		{name: "across midnight in the morning", after: "22:00", before: "06:00", now: day(3, 0), expected: true},
+		{name: "across midnight at the end", after: "22:00", before: "06:00", now: day(6, 0), expected: false},
+		{name: "across midnight at noon", after: "22:00", before: "06:00", now: day(12, 0), expected: false},
+		{name: "only after", after: "20:00", now: day(21, 0), expected: true},
+		{name: "only after too early", after: "20:00", now: day(19, 0), expected: false},
+		{name: "only before", before: "07:00", now: day(6, 0), expected: true},
+		{name: "only before too late", before: "07:00", now: day(8, 0), expected: false},
+		{name: "weekday", weekdays: []time.Weekday{time.Monday}, now: day(12, 0), expected: true},
+		{name: "other weekday", weekdays: []time.Weekday{time.Saturday, time.Sunday}, now: day(12, 0), expected: false},
+		{name: "weekday in the location", weekdays: []time.Weekday{time.Monday}, now: day(0, 30).UTC(), expected: true},
+		{name: "after sunset", after: "sunset", before: "23:00", coordinates: berlin, now: day(16, 0), expected: true},
+		{name: "before sunset", after: "sunset", before: "23:00", coordinates: berlin, now: day(15, 45), expected: false},
+		{name: "after sunset with offset", after: "sunset-30m", before: "23:00", coordinates: berlin, now: day(15, 45), expected: true},
+		{name: "sunset to sunrise", after: "sunset", before: "sunrise", coordinates: berlin, now: day(5, 0), expected: true},
+		{name: "sunrise to sunset", after: "sunrise", before: "sunset", coordinates: berlin, now: day(5, 0), expected: false},
+		{name: "no sunset in the polar night", after: "sunset", coordinates: tromso, now: day(20, 0), expected: false},
+		{name: "no sunrise in the polar night", before: "sunrise", coordinates: tromso, now: day(5, 0), expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := &Engine{location: testLocation, coordinates: test.coordinates}
+			condition := &Condition{
+				after:    mustParseClockTime(t, test.after),
+				before:   mustParseClockTime(t, test.before),
+				weekdays: test.weekdays,
+			}
+			if result := engine.inTimeWindow(condition, test.now); result != test.expected {
+				t.Errorf("expected %t at %v, got %t", test.expected, test.now, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/salex-org/ikea-dirigera-client/pkg/webhooks"
+	"gopkg.in/yaml.v3"
+)
+
+// Modes of a rule triggered while its actions are still running.
+const (
+	ModeSingle   = "single"   // The trigger is ignored
+	ModeRestart  = "restart"  // The running actions are cancelled and started again, e.g. to extend a delay
+	ModeParallel = "parallel" // The actions run once more
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Config is the content of the rule file. Values of urls and headers may reference environment variables like
+// ${NTFY_URL}, so secrets do not have to be stored in the file.
+type Config struct {
+	Notify Notify `yaml:"notify,omitempty"`
+	Rules  []Rule `yaml:"rules"`
+}
+
+// Notify defines where notify actions send their messages. Messages are only logged if no URL is defined.
+type Notify struct {
+	URL     string            `yaml:"url,omitempty"`
+	Format  string            `yaml:"format,omitempty"` // ntfy, slack, discord or json, defaults to ntfy
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Rule runs its actions when one of the triggers fires and all conditions are met.
+type Rule struct {
+	Name       string      `yaml:"name"`
+	Mode       string      `yaml:"mode,omitempty"` // single, restart or parallel, defaults to single
+	Triggers   []Trigger   `yaml:"triggers"`
+	Conditions []Condition `yaml:"conditions,omitempty"`
+	Actions    []Action    `yaml:"actions"`
+}
+
+// Trigger fires a rule. Exactly one of attribute, event, at, cron and unreachable must be defined.
+type Trigger struct {
+	// Attribute fires when an event changes the attribute. With to, the new value must be equal. With above or
+	// below, the trigger fires when the value crosses the threshold.
+	Attribute string      `yaml:"attribute,omitempty"`
+	To        interface{} `yaml:"to,omitempty"`
+	Above     *float64    `yaml:"above,omitempty"`
+	Below     *float64    `yaml:"below,omitempty"`
+	// Event fires on events of the type, e.g. deviceAdded.
+	Event string `yaml:"event,omitempty"`
+	// At fires daily at a time like 07:30, sunrise or sunset with an optional offset like sunset-30m. Sunrise and
+	// sunset are computed from the coordinates of the hub.
+	At string `yaml:"at,omitempty"`
+	// Cron fires at the times of a cron expression with five fields, e.g. "0 7 * * 1-5".
+	Cron string `yaml:"cron,omitempty"`
+	// Unreachable fires when a device is not reachable for the duration of for.
+	Unreachable bool          `yaml:"unreachable,omitempty"`
+	For         time.Duration `yaml:"for,omitempty"`
+	// Devices and Rooms restrict attribute, event and unreachable triggers to devices selected by ID or name.
+	Devices []string `yaml:"devices,omitempty"`
+	Rooms   []string `yaml:"rooms,omitempty"`
+
+	at       clockTime
+	schedule cron.Schedule
+}
+
+// Condition must be met to run the actions of a rule. Exactly one of device, a time window (after, before and
+// weekdays) and room must be defined.
+type Condition struct {
+	// Device checks an attribute of the device, selected by ID or name, to be equal to is, above or below a value.
+	Device    string      `yaml:"device,omitempty"`
+	Attribute string      `yaml:"attribute,omitempty"`
+	Is        interface{} `yaml:"is,omitempty"`
+	Above     *float64    `yaml:"above,omitempty"`
+	Below     *float64    `yaml:"below,omitempty"`
+	// After and Before define a time window like the times of at triggers, e.g. after sunset and before 23:00.
+	// Windows with after later than before span midnight.
+	After    string   `yaml:"after,omitempty"`
+	Before   string   `yaml:"before,omitempty"`
+	Weekdays []string `yaml:"weekdays,omitempty"` // mon, tue, wed, thu, fri, sat or sun
+	// Room checks if a motion or occupancy sensor in the room detects someone, or detected someone within the
+	// duration. Occupied false checks that nobody is detected.
+	Room     string        `yaml:"room,omitempty"`
+	Occupied *bool         `yaml:"occupied,omitempty"`
+	Within   time.Duration `yaml:"within,omitempty"`
+
+	after, before clockTime
+	weekdays      []time.Weekday
+}
+
+// Action is a step of a rule. Exactly one of set, scene, webhook, delay and notify must be defined.
+type Action struct {
+	// Set changes attributes of the devices, selected by ID or name, and of the devices in the rooms. Devices in
+	// rooms only receive the attributes they support.
+	Set        map[string]interface{} `yaml:"set,omitempty"`
+	Devices    []string               `yaml:"devices,omitempty"`
+	Rooms      []string               `yaml:"rooms,omitempty"`
+	Transition time.Duration          `yaml:"transition,omitempty"`
+	// Scene triggers the scene with the ID or name.
+	Scene string `yaml:"scene,omitempty"`
+	// Webhook posts the body, a Go template defaulting to the JSON of the trigger data, to the URL.
+	Webhook     string            `yaml:"webhook,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Body        string            `yaml:"body,omitempty"`
+	ContentType string            `yaml:"content_type,omitempty"`
+	// Delay waits before the next action.
+	Delay time.Duration `yaml:"delay,omitempty"`
+	// Notify sends the message, a Go template, to the notify URL.
+	Notify string `yaml:"notify,omitempty"`
+
+	body    *template.Template
+	message *template.Template
+}
+
+// clockTime is a time of day or a time relative to sunrise or sunset.
+type clockTime struct {
+	defined bool
+	sun     string // sunrise or sunset, empty for a time of day
+	offset  time.Duration
+}
+
+// LoadConfig reads the rule file, expands environment variables and validates the rules.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rule file: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("could not parse rule file %s: %w", path, err)
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("no rules defined in %s", path)
+	}
+
+	var errs []error
+	if err := config.Notify.prepare(); err != nil {
+		errs = append(errs, fmt.Errorf("notify: %w", err))
+	}
+	names := make(map[string]bool, len(config.Rules))
+	// Log files are named like the rules, case-insensitive file systems do not distinguish the case
+	logNames := make(map[string]string, len(config.Rules))
+	for index := range config.Rules {
+		rule := &config.Rules[index]
+		if err := rule.prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", index+1, err))
+		}
+		if names[rule.Name] {
+			errs = append(errs, fmt.Errorf("rule %d: name %s is used more than once", index+1, rule.Name))
+		}
+		names[rule.Name] = true
+		logName := ruleLogPath("", rule.Name)
+		if other, found := logNames[strings.ToLower(logName)]; found && other != rule.Name {
+			errs = append(errs, fmt.Errorf("rule %d: name %s has the same log file %s as rule %s", index+1, rule.Name, logName, other))
+		}
+		logNames[strings.ToLower(logName)] = rule.Name
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// UsesSun returns true if a rule depends on sunrise or sunset, which requires the coordinates of the hub.
+func (c *Config) UsesSun() bool {
+	for _, rule := range c.Rules {
+		for _, trigger := range rule.Triggers {
+			if trigger.at.sun != "" {
+				return true
+			}
+		}
+		for _, condition := range rule.Conditions {
+			if condition.after.sun != "" || condition.before.sun != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (n *Notify) prepare() error {
+	n.URL = os.ExpandEnv(n.URL)
+	for name, value := range n.Headers {
+		n.Headers[name] = os.ExpandEnv(value)
+	}
+	if n.Format == "" {
+		n.Format = webhooks.FormatNtfy
+	}
+	if !slices.Contains(webhooks.Formats, n.Format) {
+		return fmt.Errorf("invalid format %s: must be ntfy, slack, discord or json", n.Format)
+	}
+	if n.URL != "" && !isHTTPURL(n.URL) {
+		return fmt.Errorf("invalid url: must be an http or https URL")
+	}
+
+	return nil
+}
+
+// prepare validates the rule, applies the defaults and parses times and templates.
+func (r *Rule) prepare() error {
+	if r.Name == "" {
+		return fmt.Errorf("name missing")
+	}
+	if r.Mode == "" {
+		r.Mode = ModeSingle
+	}
+	if !slices.Contains([]string{ModeSingle, ModeRestart, ModeParallel}, r.Mode) {
+		return fmt.Errorf("invalid mode %s of rule %s: must be single, restart or parallel", r.Mode, r.Name)
+	}
+	if len(r.Triggers) == 0 {
+		return fmt.Errorf("no triggers defined in rule %s", r.Name)
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("no actions defined in rule %s", r.Name)
+	}
+
+	var errs []error
+	for index := range r.Triggers {
+		if err := r.Triggers[index].prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("trigger %d of rule %s: %w", index+1, r.Name, err))
+		}
+	}
+	for index := range r.Conditions {
+		if err := r.Conditions[index].prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("condition %d of rule %s: %w", index+1, r.Name, err))
+		}
+	}
+	for index := range r.Actions {
+		if err := r.Actions[index].prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("action %d of rule %s: %w", index+1, r.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Trigger) prepare() error {
+	if count(t.Attribute != "", t.Event != "", t.At != "", t.Cron != "", t.Unreachable) != 1 {
+		return fmt.Errorf("exactly one of attribute, event, at, cron and unreachable must be defined")
+	}
+	if t.Attribute == "" && (t.To != nil || t.Above != nil || t.Below != nil) {
+		return fmt.Errorf("to, above and below require an attribute")
+	}
+	if t.To != nil && (t.Above != nil || t.Below != nil) {
+		return fmt.Errorf("to can not be combined with above or below")
+	}
+	if (t.At != "" || t.Cron != "") && (len(t.Devices) > 0 || len(t.Rooms) > 0) {
+		return fmt.Errorf("devices and rooms can not be combined with at or cron")
+	}
+	if !t.Unreachable && t.For != 0 {
+		return fmt.Errorf("for requires unreachable")
+	}
+	if t.Unreachable && t.For <= 0 {
+		return fmt.Errorf("unreachable requires a positive duration in for, e.g. 10m")
+	}
+
+	var err error
+	switch {
+	case t.At != "":
+		t.at, err = parseClockTime(t.At)
+	case t.Cron != "":
+		if t.schedule, err = cron.ParseStandard(t.Cron); err != nil {
+			err = fmt.Errorf("invalid cron expression %s: %w", t.Cron, err)
+		}
+	}
+
+	return err
+}
+
+func (c *Condition) prepare() error {
+	timeWindow := c.After != "" || c.Before != "" || len(c.Weekdays) > 0
+	if count(c.Device != "", timeWindow, c.Room != "") != 1 {
+		return fmt.Errorf("exactly one of device, a time window and room must be defined")
+	}
+	if c.Device != "" {
+		if c.Attribute == "" {
+			return fmt.Errorf("attribute of device %s missing", c.Device)
+		}
+		if count(c.Is != nil, c.Above != nil || c.Below != nil) != 1 {
+			return fmt.Errorf("either is or above and below must be defined for device %s", c.Device)
+		}
+	}
+	if c.Room == "" && (c.Occupied != nil || c.Within != 0) {
+		return fmt.Errorf("occupied and within require a room")
+	}
+
+	var err error
+	if c.after, err = parseClockTime(c.After); err != nil {
+		return err
+	}
+	if c.before, err = parseClockTime(c.Before); err != nil {
+		return err
+	}
+	for _, name := range c.Weekdays {
+		weekday, valid := weekdays[strings.ToLower(name)]
+		if !valid {
+			return fmt.Errorf("invalid weekday %s: must be mon, tue, wed, thu, fri, sat or sun", name)
+		}
+		c.weekdays = append(c.weekdays, weekday)
+	}
+
+	return nil
+}
+
+func (a *Action) prepare() error {
+	if count(len(a.Set) > 0, a.Scene != "", a.Webhook != "", a.Delay > 0, a.Notify != "") != 1 {
+		return fmt.Errorf("exactly one of set, scene, webhook, delay and notify must be defined")
+	}
+	if len(a.Set) > 0 && len(a.Devices) == 0 && len(a.Rooms) == 0 {
+		return fmt.Errorf("set requires devices or rooms")
+	}
+
+	var err error
+	switch {
+	case a.Webhook != "":
+		a.Webhook = os.ExpandEnv(a.Webhook)
+		for name, value := range a.Headers {
+			a.Headers[name] = os.ExpandEnv(value)
+		}
+		if !isHTTPURL(a.Webhook) {
+			return fmt.Errorf("invalid webhook: must be an http or https URL")
+		}
+		body := a.Body
+		if body == "" {
+			body = `{{ json . }}`
+		}
+		if a.ContentType == "" {
+			a.ContentType = "application/json"
+		}
+		if a.body, err = newTemplate("body", body); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	case a.Notify != "":
+		if a.message, err = newTemplate("notify", a.Notify); err != nil {
+			return fmt.Errorf("invalid notify message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// parseClockTime parses HH:MM, sunrise or sunset with an optional offset like sunset-30m. An empty value returns
+// an undefined time.
+func parseClockTime(value string) (clockTime, error) {
+	if value == "" {
+		return clockTime{}, nil
+	}
+	for _, sun := range []string{"sunrise", "sunset"} {
+		rest, found := strings.CutPrefix(strings.ToLower(value), sun)
+		if !found {
+			continue
+		}
+		result := clockTime{defined: true, sun: sun}
+		if rest != "" {
+			offset, err := time.ParseDuration(strings.TrimPrefix(rest, "+"))
+			if err != nil || (rest[0] != '+' && rest[0] != '-') {
+				return clockTime{}, fmt.Errorf("invalid offset %s of %s: must be like +30m or -1h", rest, sun)
+			}
+			result.offset = offset
+		}
+		return result, nil
+	}
+
+	hour, minute, found := strings.Cut(value, ":")
+	hours, hourErr := strconv.Atoi(hour)
+	minutes, minuteErr := strconv.Atoi(minute)
+	if !found || hourErr != nil || minuteErr != nil || hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return clockTime{}, fmt.Errorf("invalid time %s: must be HH:MM, sunrise or sunset", value)
+	}
+
+	return clockTime{defined: true, offset: time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute}, nil
+}
+
+func isHTTPURL(value string) bool {
+	target, err := url.Parse(value)
+
+	return err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != ""
+}
+
+func count(values ...bool) int {
+	result := 0
+	for _, value := range values {
+		if value {
+			result++
+		}
+	}
+
+	return result
+}
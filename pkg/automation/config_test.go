@@ -0,0 +1,39 @@
+package automation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected clockTime
+	}{
+		{"", clockTime{}},
+		{"00:00", clockTime{defined: true}},
+		{"07:30", clockTime{defined: true, offset: 7*time.Hour + 30*time.Minute}},
+		{"7:05", clockTime{defined: true, offset: 7*time.Hour + 5*time.Minute}},
+		{"23:59", clockTime{defined: true, offset: 23*time.Hour + 59*time.Minute}},
+		{"sunrise", clockTime{defined: true, sun: "sunrise"}},
+		{"Sunset", clockTime{defined: true, sun: "sunset"}},
+		{"sunset-30m", clockTime{defined: true, sun: "sunset", offset: -30 * time.Minute}},
+		{"sunrise+1h15m", clockTime{defined: true, sun: "sunrise", offset: 75 * time.Minute}},
+	}
+	for _, test := range tests {
+		result, err := parseClockTime(test.value)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", test.value, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("expected %+v for %q, got %+v", test.expected, test.value, result)
+		}
+	}
+
+	for _, value := range []string{"24:00", "12:60", "-1:00", "12", "noon", "sunset30m", "sunrise+", "sunset-soon"} {
+		if _, err := parseClockTime(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
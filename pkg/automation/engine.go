@@ -0,0 +1,313 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+// Options configures an Engine.
+type Options struct {
+	// DryRun only logs the actions changing devices, triggering scenes or sending requests.
+	DryRun bool
+	// LogDirectory receives a log file <rule>.log for every rule in addition to the logger if not empty.
+	LogDirectory string
+	// Logger may be nil.
+	Logger *slog.Logger
+}
+
+// Data describes what fired a rule. It is available in the templates of webhook bodies and notify messages.
+type Data struct {
+	Rule       string                 `json:"rule"`
+	Trigger    string                 `json:"trigger"` // Description of the trigger, e.g. "isDetected of Motion sensor"
+	Time       time.Time              `json:"time"`
+	DeviceID   string                 `json:"deviceId,omitempty"`
+	Device     string                 `json:"device,omitempty"` // Name of the device, the ID if not named
+	DeviceType string                 `json:"deviceType,omitempty"`
+	Room       string                 `json:"room,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"` // Attributes changed by the event
+	State      map[string]interface{} `json:"state"`      // All attributes of the device after the event
+}
+
+// Engine runs the rules of a Config on the events of the hub and at the scheduled times.
+type Engine struct {
+	dirigeraClient client.Client
+	config         *Config
+	options        Options
+	httpClient     *http.Client
+	location       *time.Location
+	coordinates    *client.Coordinates
+	runners        []*ruleRunner
+	ctx            context.Context
+	devices        *client.DeviceCache
+	mutex          sync.Mutex
+	detected       map[string]time.Time // Last time a sensor detected someone
+	stopped        bool
+	executions     sync.WaitGroup
+}
+
+// ruleRunner keeps the running actions and pending unreachable triggers of a rule.
+type ruleRunner struct {
+	rule        *Rule
+	logger      *slog.Logger
+	mutex       sync.Mutex
+	running     map[*execution]struct{}
+	unreachable map[string]*time.Timer // By trigger index and device ID
+}
+
+type execution struct {
+	cancel context.CancelFunc
+}
+
+// New creates an Engine for the rules of the config.
+func New(dirigeraClient client.Client, config *Config, options Options) *Engine {
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+	engine := &Engine{
+		dirigeraClient: dirigeraClient,
+		config:         config,
+		options:        options,
+		httpClient:     &http.Client{},
+		location:       time.Local,
+		devices:        client.NewDeviceCache(dirigeraClient, options.Logger),
+		detected:       make(map[string]time.Time),
+	}
+	for index := range config.Rules {
+		engine.runners = append(engine.runners, &ruleRunner{
+			rule:        &config.Rules[index],
+			logger:      options.Logger.With("rule", config.Rules[index].Name),
+			running:     make(map[*execution]struct{}),
+			unreachable: make(map[string]*time.Timer),
+		})
+	}
+
+	return engine
+}
+
+// Run reads the hub and the devices and runs the rules until the context is cancelled. Running actions are
+// cancelled then.
+func (e *Engine) Run(ctx context.Context) error {
+	hub, err := e.dirigeraClient.GetHub()
+	if err != nil {
+		return fmt.Errorf("could not read hub: %w", err)
+	}
+	if hub.Timezone != "" {
+		if location, err := time.LoadLocation(hub.Timezone); err == nil {
+			e.location = location
+		} else {
+			e.options.Logger.Warn("unknown timezone of hub, using local time", "timezone", hub.Timezone, "error", err)
+		}
+	}
+	e.coordinates = hub.Coordinates
+	if e.config.UsesSun() && e.coordinates == nil {
+		return fmt.Errorf("sunrise and sunset require the coordinates of the hub, set them with \"ikea set hub --latitude <latitude> --longitude <longitude>\"")
+	}
+	if _, err := e.devices.Load(); err != nil {
+		return err
+	}
+
+	logFiles, err := e.openRuleLogs()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, file := range logFiles {
+			_ = file.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.ctx = ctx
+	var schedules sync.WaitGroup
+	for _, runner := range e.runners {
+		for index := range runner.rule.Triggers {
+			if trigger := &runner.rule.Triggers[index]; trigger.At != "" || trigger.Cron != "" {
+				schedules.Go(func() {
+					e.schedule(ctx, runner, trigger)
+				})
+			}
+		}
+	}
+	defer e.stop(cancel, &schedules)
+
+	return client.RunEvents(ctx, e.dirigeraClient, e.handleEvent, e.devices.Reload, e.options.Logger)
+}
+
+// stop cancels the running actions, pending triggers and schedules and waits for them to end.
+func (e *Engine) stop(cancel context.CancelFunc, schedules *sync.WaitGroup) {
+	cancel()
+	e.mutex.Lock()
+	e.stopped = true
+	e.mutex.Unlock()
+	for _, runner := range e.runners {
+		runner.mutex.Lock()
+		for _, timer := range runner.unreachable {
+			timer.Stop()
+		}
+		runner.mutex.Unlock()
+	}
+	schedules.Wait()
+	e.executions.Wait()
+}
+
+func (e *Engine) handleEvent(event client.Event) {
+	previous, device := e.updateDevice(event)
+	data := newData(event, device)
+	for _, runner := range e.runners {
+		for index := range runner.rule.Triggers {
+			trigger := &runner.rule.Triggers[index]
+			switch {
+			case trigger.Unreachable:
+				e.watchReachability(runner, index, previous, device)
+			case trigger.matchesEvent(event, previous, device):
+				e.fire(runner, trigger.describe(device), data)
+			}
+		}
+	}
+}
+
+// updateDevice keeps the known state of the devices and the last detections of sensors up to date and returns
+// the state before and after the event. Both are nil for unknown devices.
+func (e *Engine) updateDevice(event client.Event) (previous, device *client.Device) {
+	previous, device = e.devices.Apply(event)
+	if device == nil {
+		device = previous
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if detected, _ := event.Device.Attributes["isDetected"].(bool); detected {
+		e.detected[event.Device.ID] = time.Now()
+	}
+	if event.Type == "deviceRemoved" {
+		delete(e.detected, event.Device.ID)
+	}
+
+	return previous, device
+}
+
+// fire runs the actions of the rule if its conditions are met, depending on the mode of the rule.
+func (e *Engine) fire(runner *ruleRunner, trigger string, data Data) {
+	data.Rule = runner.rule.Name
+	data.Trigger = trigger
+	logger := runner.logger
+	logger.Info("rule triggered", "trigger", trigger)
+	if condition := e.unmetCondition(runner.rule, time.Now()); condition != "" {
+		logger.Info("condition not met", "condition", condition)
+		return
+	}
+
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+	switch runner.rule.Mode {
+	case ModeSingle:
+		if len(runner.running) > 0 {
+			logger.Info("rule already running, trigger ignored")
+			return
+		}
+	case ModeRestart:
+		for running := range runner.running {
+			running.cancel()
+			delete(runner.running, running)
+			logger.Info("restarting rule")
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.stopped {
+		return
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	current := &execution{cancel: cancel}
+	runner.running[current] = struct{}{}
+	e.executions.Go(func() {
+		defer func() {
+			cancel()
+			runner.mutex.Lock()
+			delete(runner.running, current)
+			runner.mutex.Unlock()
+		}()
+		e.execute(ctx, runner, data)
+	})
+}
+
+// execute runs the actions of the rule one after another. The remaining actions are skipped if one fails.
+func (e *Engine) execute(ctx context.Context, runner *ruleRunner, data Data) {
+	for index := range runner.rule.Actions {
+		if err := e.runAction(ctx, runner, &runner.rule.Actions[index], data); err != nil {
+			if ctx.Err() != nil {
+				runner.logger.Info("rule cancelled", "action", index+1)
+			} else {
+				runner.logger.Error("action failed, skipping remaining actions", "action", index+1, "error", err)
+			}
+			return
+		}
+	}
+	runner.logger.Info("rule finished")
+}
+
+func newData(event client.Event, device *client.Device) Data {
+	data := Data{
+		Time:       event.Time,
+		DeviceID:   event.Device.ID,
+		Device:     event.Device.ID,
+		Attributes: event.Device.Attributes,
+		State:      event.Device.Attributes,
+	}
+	if data.Time.IsZero() {
+		data.Time = time.Now()
+	}
+	if data.Attributes == nil {
+		data.Attributes = map[string]interface{}{}
+	}
+	if device != nil {
+		if name := device.CustomName(); name != "" {
+			data.Device = name
+		}
+		data.DeviceType = device.Type
+		data.Room = device.Room.Name
+		data.State = device.Attributes
+	}
+	if data.State == nil {
+		data.State = map[string]interface{}{}
+	}
+
+	return data
+}
+
+// openRuleLogs opens the log files of the rules in the log directory and adds them to the loggers of the rules.
+func (e *Engine) openRuleLogs() ([]*os.File, error) {
+	if e.options.LogDirectory == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(e.options.LogDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create log directory: %w", err)
+	}
+
+	var files []*os.File
+	for _, runner := range e.runners {
+		file, err := os.OpenFile(ruleLogPath(e.options.LogDirectory, runner.rule.Name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			for _, opened := range files {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("could not open log of rule %s: %w", runner.rule.Name, err)
+		}
+		files = append(files, file)
+		runner.logger = slog.New(teeHandler{
+			e.options.Logger.Handler(),
+			slog.NewTextHandler(file, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		}).With("rule", runner.rule.Name)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,39 @@
+package automation
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch = 2440587.5 // Julian date of 1970-01-01 00:00 UTC
+	julian2000      = 2451545.0 // Julian date of 2000-01-01 12:00 UTC
+	degrees         = math.Pi / 180
+)
+
+// sunTimes returns sunrise and sunset of the day of date at the coordinates with the sunrise equation of NOAA.
+// ok is false on days without sunrise or sunset, e.g. during the polar night.
+func sunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	day := math.Round(float64(noon.Unix())/86400 + julianUnixEpoch - julian2000 + 0.0008)
+	solarNoon := day - longitude/360
+
+	anomaly := math.Mod(357.5291+0.98560028*solarNoon, 360)
+	center := 1.9148*math.Sin(anomaly*degrees) + 0.02*math.Sin(2*anomaly*degrees) + 0.0003*math.Sin(3*anomaly*degrees)
+	eclipticLongitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := julian2000 + solarNoon + 0.0053*math.Sin(anomaly*degrees) - 0.0069*math.Sin(2*eclipticLongitude*degrees)
+
+	declination := math.Asin(math.Sin(eclipticLongitude*degrees) * math.Sin(23.4397*degrees))
+	cosHourAngle := (math.Sin(-0.833*degrees) - math.Sin(latitude*degrees)*math.Sin(declination)) /
+		(math.Cos(latitude*degrees) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) / degrees
+
+	return julianTime(transit - hourAngle/360).In(date.Location()), julianTime(transit + hourAngle/360).In(date.Location()), true
+}
+
+func julianTime(julianDate float64) time.Time {
+	return time.Unix(0, int64((julianDate-julianUnixEpoch)*86400*float64(time.Second)))
+}
@@ -0,0 +1,94 @@
+package automation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      time.Time
+		latitude  float64
+		longitude float64
+		sunrise   time.Time
+		sunset    time.Time
+		ok        bool
+	}{
+		{
+			name:      "Berlin at the summer solstice",
+			date:      time.Date(2025, time.June, 21, 0, 0, 0, 0, time.UTC),
+			latitude:  52.52,
+			longitude: 13.405,
+			sunrise:   time.Date(2025, time.June, 21, 2, 43, 0, 0, time.UTC),
+			sunset:    time.Date(2025, time.June, 21, 19, 33, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{
+			name:      "Berlin at the winter solstice",
+			date:      time.Date(2025, time.December, 21, 0, 0, 0, 0, time.UTC),
+			latitude:  52.52,
+			longitude: 13.405,
+			sunrise:   time.Date(2025, time.December, 21, 7, 15, 0, 0, time.UTC),
+			sunset:    time.Date(2025, time.December, 21, 14, 54, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{
+			name:    "equator at the equinox",
+			date:    time.Date(2025, time.March, 20, 0, 0, 0, 0, time.UTC),
+			sunrise: time.Date(2025, time.March, 20, 6, 4, 0, 0, time.UTC),
+			sunset:  time.Date(2025, time.March, 20, 18, 11, 0, 0, time.UTC),
+			ok:      true,
+		},
+		{
+			name:      "returned in the location of the date",
+			date:      time.Date(2025, time.June, 21, 0, 0, 0, 0, time.FixedZone("AEST", 10*3600)),
+			latitude:  -33.87,
+			longitude: 151.21,
+			sunrise:   time.Date(2025, time.June, 21, 7, 0, 0, 0, time.FixedZone("AEST", 10*3600)),
+			sunset:    time.Date(2025, time.June, 21, 16, 54, 0, 0, time.FixedZone("AEST", 10*3600)),
+			ok:        true,
+		},
+		{
+			name:      "Tromsø after the polar night",
+			date:      time.Date(2025, time.January, 20, 0, 0, 0, 0, time.UTC),
+			latitude:  69.65,
+			longitude: 18.96,
+			sunrise:   time.Date(2025, time.January, 20, 9, 37, 0, 0, time.UTC),
+			sunset:    time.Date(2025, time.January, 20, 12, 13, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{
+			name:      "Tromsø in the polar night",
+			date:      time.Date(2025, time.December, 21, 0, 0, 0, 0, time.UTC),
+			latitude:  69.65,
+			longitude: 18.96,
+		},
+		{
+			name:      "Tromsø in the midnight sun",
+			date:      time.Date(2025, time.June, 21, 0, 0, 0, 0, time.UTC),
+			latitude:  69.65,
+			longitude: 18.96,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sunrise, sunset, ok := sunTimes(test.date, test.latitude, test.longitude)
+			if ok != test.ok {
+				t.Fatalf("expected ok %t, got %t", test.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if difference := sunrise.Sub(test.sunrise).Abs(); difference > time.Minute {
+				t.Errorf("expected sunrise at %v, got %v", test.sunrise, sunrise)
+			}
+			if difference := sunset.Sub(test.sunset).Abs(); difference > time.Minute {
+				t.Errorf("expected sunset at %v, got %v", test.sunset, sunset)
+			}
+			if sunrise.Location() != test.date.Location() {
+				t.Errorf("expected location %v, got %v", test.date.Location(), sunrise.Location())
+			}
+		})
+	}
+}
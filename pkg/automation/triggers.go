@@ -0,0 +1,175 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+// maxSunDays limits the search for the next sunrise or sunset, which may not occur for months near the poles.
+const maxSunDays = 366
+
+// matchesEvent returns true if an attribute or event trigger fires for the event.
+func (t *Trigger) matchesEvent(event client.Event, previous, device *client.Device) bool {
+	if t.Attribute == "" && t.Event == "" {
+		return false
+	}
+	if !selects(t.Devices, t.Rooms, event.Device.ID, device) {
+		return false
+	}
+	if t.Event != "" {
+		return event.Type == t.Event
+	}
+
+	value, changed := event.Device.Attributes[t.Attribute]
+	switch {
+	case !changed:
+		return false
+	case t.To != nil:
+		return equalValues(value, t.To)
+	case t.Above != nil || t.Below != nil:
+		// Thresholds fire when they are crossed, not on every change beyond them
+		if !inRange(value, t.Above, t.Below) {
+			return false
+		}
+		if previous == nil {
+			return true
+		}
+		before, known := previous.Attributes[t.Attribute]
+		return !known || !inRange(before, t.Above, t.Below)
+	default:
+		return true
+	}
+}
+
+// describe returns a description of the trigger for logs and templates.
+func (t *Trigger) describe(device *client.Device) string {
+	name := ""
+	if device != nil {
+		name = device.CustomName()
+		if name == "" {
+			name = device.ID
+		}
+	}
+	switch {
+	case t.Attribute != "":
+		return fmt.Sprintf("%s of %s", t.Attribute, name)
+	case t.Event != "":
+		return fmt.Sprintf("%s of %s", t.Event, name)
+	case t.At != "":
+		return "at " + t.At
+	case t.Cron != "":
+		return "cron " + t.Cron
+	default:
+		return fmt.Sprintf("%s unreachable for %s", name, t.For)
+	}
+}
+
+// watchReachability starts the timer of an unreachable trigger when a selected device becomes unreachable and
+// stops it when the device is reachable again.
+func (e *Engine) watchReachability(runner *ruleRunner, index int, previous, device *client.Device) {
+	trigger := &runner.rule.Triggers[index]
+	if device == nil || !selects(trigger.Devices, trigger.Rooms, device.ID, device) {
+		return
+	}
+	key := fmt.Sprintf("%d/%s", index, device.ID)
+
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+	timer, pending := runner.unreachable[key]
+	switch {
+	case device.IsReachable && pending:
+		timer.Stop()
+		delete(runner.unreachable, key)
+	case !device.IsReachable && !pending && previous != nil && previous.IsReachable:
+		runner.unreachable[key] = time.AfterFunc(trigger.For, func() {
+			runner.mutex.Lock()
+			delete(runner.unreachable, key)
+			runner.mutex.Unlock()
+			current := e.devices.Get(device.ID)
+			if current == nil || current.IsReachable {
+				return
+			}
+			data := newData(client.Event{Type: "deviceStateChanged", Device: client.Device{ID: device.ID}}, current)
+			e.fire(runner, trigger.describe(current), data)
+		})
+	}
+}
+
+// schedule fires an at or cron trigger at its times until the context is cancelled.
+func (e *Engine) schedule(ctx context.Context, runner *ruleRunner, trigger *Trigger) {
+	for {
+		next, found := e.nextTime(trigger, time.Now())
+		if !found {
+			runner.logger.Warn("trigger never fires", "trigger", trigger.describe(nil))
+			return
+		}
+		runner.logger.Debug("trigger scheduled", "trigger", trigger.describe(nil), "next", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		e.fire(runner, trigger.describe(nil), Data{
+			Time:       next,
+			Attributes: map[string]interface{}{},
+			State:      map[string]interface{}{},
+		})
+	}
+}
+
+// nextTime returns the next time after now an at or cron trigger fires.
+func (e *Engine) nextTime(trigger *Trigger, now time.Time) (time.Time, bool) {
+	now = now.In(e.location)
+	if trigger.schedule != nil {
+		next := trigger.schedule.Next(now)
+		return next, !next.IsZero()
+	}
+	for day := range maxSunDays {
+		if next, found := e.resolve(trigger.at, now.AddDate(0, 0, day)); found && next.After(now) {
+			return next, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// resolve returns the clock time on the day of date, which is false if the sun does not rise or set that day.
+func (e *Engine) resolve(clock clockTime, date time.Time) (time.Time, bool) {
+	date = date.In(e.location)
+	if clock.sun == "" {
+		return time.Date(date.Year(), date.Month(), date.Day(), int(clock.offset.Hours()), int(clock.offset.Minutes())%60, 0, 0, e.location), true
+	}
+	sunrise, sunset, found := sunTimes(date, e.coordinates.Latitude, e.coordinates.Longitude)
+	if !found {
+		return time.Time{}, false
+	}
+	if clock.sun == "sunrise" {
+		return sunrise.Add(clock.offset), true
+	}
+
+	return sunset.Add(clock.offset), true
+}
+
+// selects returns true if the device is selected by ID or name and is in one of the rooms. Empty selectors
+// select all devices.
+func selects(devices, rooms []string, deviceID string, device *client.Device) bool {
+	if len(devices) > 0 && !slices.ContainsFunc(devices, func(selector string) bool {
+		return selector == deviceID || (device != nil && strings.EqualFold(selector, device.CustomName()))
+	}) {
+		return false
+	}
+	if len(rooms) > 0 && !slices.ContainsFunc(rooms, func(selector string) bool {
+		return device != nil && (selector == device.Room.ID || strings.EqualFold(selector, device.Room.Name))
+	}) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,256 @@
+package automation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+func TestMatchesEvent(t *testing.T) {
+	above := 25.0
+	below := 18.0
+	sensor := func(attributes map[string]interface{}) *client.Device {
+		attributes["customName"] = "Thermometer"
+		return &client.Device{ID: "sensor-1", Room: client.Room{ID: "room-1", Name: "Living room"}, Attributes: attributes}
+	}
+	event := func(eventType string, attributes map[string]interface{}) client.Event {
+		return client.Event{Type: eventType, Device: client.Device{ID: "sensor-1", Attributes: attributes}}
+	}
+	tests := []struct {
+		name     string
+		trigger  Trigger
+		event    client.Event
+		previous *client.Device
+		expected bool
+	}{
+		{
+			name:     "attribute changed",
+			trigger:  Trigger{Attribute: "isOpen"},
+			event:    event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+			expected: true,
+		},
+		{
+			name:    "other attribute changed",
+			trigger: Trigger{Attribute: "isOpen"},
+			event:   event("deviceStateChanged", map[string]interface{}{"batteryPercentage": 80.0}),
+		},
+		{
+			name:     "changed to the value",
+			trigger:  Trigger{Attribute: "isOpen", To: true},
+			event:    event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+			expected: true,
+		},
+		{
+			name:    "changed to another value",
+			trigger: Trigger{Attribute: "isOpen", To: true},
+			event:   event("deviceStateChanged", map[string]interface{}{"isOpen": false}),
+		},
+		{
+			name:     "number compared by value",
+			trigger:  Trigger{Attribute: "lightLevel", To: 50},
+			event:    event("deviceStateChanged", map[string]interface{}{"lightLevel": 50.0}),
+			expected: true,
+		},
+		{
+			name:     "crossing above",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 25.5}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 24.0}),
+			expected: true,
+		},
+		{
+			name:     "staying above",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 27.0}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 26.0}),
+		},
+		{
+			name:     "reaching the threshold",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 25.0}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 24.0}),
+		},
+		{
+			name:     "crossing below",
+			trigger:  Trigger{Attribute: "currentTemperature", Below: &below},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 17.5}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 18.0}),
+			expected: true,
+		},
+		{
+			name:     "entering the range",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &below, Below: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 21.0}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 26.0}),
+			expected: true,
+		},
+		{
+			name:     "leaving the range",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &below, Below: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 26.0}),
+			previous: sensor(map[string]interface{}{"currentTemperature": 21.0}),
+		},
+		{
+			name:     "above without previous state",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 26.0}),
+			expected: true,
+		},
+		{
+			name:     "above without previous value",
+			trigger:  Trigger{Attribute: "currentTemperature", Above: &above},
+			event:    event("deviceStateChanged", map[string]interface{}{"currentTemperature": 26.0}),
+			previous: sensor(map[string]interface{}{}),
+			expected: true,
+		},
+		{
+			name:    "threshold of no number",
+			trigger: Trigger{Attribute: "currentTemperature", Above: &above},
+			event:   event("deviceStateChanged", map[string]interface{}{"currentTemperature": "hot"}),
+		},
+		{
+			name:     "event type",
+			trigger:  Trigger{Event: "deviceAdded"},
+			event:    event("deviceAdded", nil),
+			expected: true,
+		},
+		{
+			name:    "other event type",
+			trigger: Trigger{Event: "deviceAdded"},
+			event:   event("deviceRemoved", nil),
+		},
+		{
+			name:     "device by name",
+			trigger:  Trigger{Attribute: "isOpen", Devices: []string{"thermometer"}},
+			event:    event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+			expected: true,
+		},
+		{
+			name:    "other device",
+			trigger: Trigger{Attribute: "isOpen", Devices: []string{"Front door"}},
+			event:   event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+		},
+		{
+			name:     "room",
+			trigger:  Trigger{Attribute: "isOpen", Rooms: []string{"living room"}},
+			event:    event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+			expected: true,
+		},
+		{
+			name:    "at trigger",
+			trigger: Trigger{At: "07:00"},
+			event:   event("deviceStateChanged", map[string]interface{}{"isOpen": true}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			device := sensor(map[string]interface{}{})
+			if result := test.trigger.matchesEvent(test.event, test.previous, device); result != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestNextTime(t *testing.T) {
+	// Monday, sunset in Berlin is at 15:53
+	now := time.Date(2025, time.December, 22, 12, 0, 0, 0, testLocation)
+	tests := []struct {
+		name        string
+		at          string
+		cron        string
+		coordinates *client.Coordinates
+		now         time.Time
+		expected    time.Time
+		found       bool
+	}{
+		{
+			name:     "later today",
+			at:       "18:30",
+			now:      now,
+			expected: time.Date(2025, time.December, 22, 18, 30, 0, 0, testLocation),
+			found:    true,
+		},
+		{
+			name:     "tomorrow",
+			at:       "07:30",
+			now:      now,
+			expected: time.Date(2025, time.December, 23, 7, 30, 0, 0, testLocation),
+			found:    true,
+		},
+		{
+			name:     "now is excluded",
+			at:       "12:00",
+			now:      now,
+			expected: time.Date(2025, time.December, 23, 12, 0, 0, 0, testLocation),
+			found:    true,
+		},
+		{
+			name:     "in the location of the engine",
+			at:       "18:30",
+			now:      now.UTC(),
+			expected: time.Date(2025, time.December, 22, 18, 30, 0, 0, testLocation),
+			found:    true,
+		},
+		{
+			name:        "sunset with offset",
+			at:          "sunset-30m",
+			coordinates: berlin,
+			now:         now,
+			expected:    time.Date(2025, time.December, 22, 15, 24, 0, 0, testLocation),
+			found:       true,
+		},
+		{
+			name:        "sunrise tomorrow",
+			at:          "sunrise",
+			coordinates: berlin,
+			now:         now,
+			expected:    time.Date(2025, time.December, 23, 8, 16, 0, 0, testLocation),
+			found:       true,
+		},
+		{
+			name:        "first sunrise after the polar night",
+			at:          "sunrise",
+			coordinates: tromso,
+			now:         now,
+			expected:    time.Date(2026, time.January, 15, 11, 42, 0, 0, testLocation),
+			found:       true,
+		},
+		{
+			name:     "cron",
+			cron:     "0 7 * * 1-5",
+			now:      now,
+			expected: time.Date(2025, time.December, 23, 7, 0, 0, 0, testLocation),
+			found:    true,
+		},
+		{
+			name:     "cron skipping the weekend",
+			cron:     "0 7 * * 1-5",
+			now:      time.Date(2025, time.December, 26, 12, 0, 0, 0, testLocation),
+			expected: time.Date(2025, time.December, 29, 7, 0, 0, 0, testLocation),
+			found:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := &Engine{location: testLocation, coordinates: test.coordinates}
+			trigger := &Trigger{at: mustParseClockTime(t, test.at)}
+			if test.cron != "" {
+				schedule, err := cron.ParseStandard(test.cron)
+				if err != nil {
+					t.Fatalf("invalid cron expression %s: %v", test.cron, err)
+				}
+				trigger.schedule = schedule
+			}
+			next, found := engine.nextTime(trigger, test.now)
+			if found != test.found {
+				t.Fatalf("expected found %t, got %t", test.found, found)
+			}
+			if difference := next.Sub(test.expected).Abs(); difference > time.Minute {
+				t.Errorf("expected %v, got %v", test.expected, next)
+			}
+		})
+	}
+}
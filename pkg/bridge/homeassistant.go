@@ -0,0 +1,268 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const DefaultDiscoveryPrefix = "homeassistant"
+
+// discoveryEntity is a Home Assistant entity of a device, published to <prefix>/<component>/<node>/<object>/config.
+type discoveryEntity struct {
+	component string
+	object    string
+	config    map[string]interface{}
+}
+
+type sensorDefinition struct {
+	attribute   string
+	name        string
+	deviceClass string
+	unit        string
+	stateClass  string
+	diagnostic  bool
+}
+
+var sensorDefinitions = []sensorDefinition{
+	{attribute: "currentTemperature", name: "Temperature", deviceClass: "temperature", unit: "°C", stateClass: "measurement"},
+	{attribute: "currentRH", name: "Humidity", deviceClass: "humidity", unit: "%", stateClass: "measurement"},
+	{attribute: "currentPM25", name: "PM2.5", deviceClass: "pm25", unit: "µg/m³", stateClass: "measurement"},
+	{attribute: "currentCO2", name: "CO2", deviceClass: "carbon_dioxide", unit: "ppm", stateClass: "measurement"},
+	{attribute: "vocIndex", name: "VOC index", stateClass: "measurement"},
+	{attribute: "illuminance", name: "Illuminance", deviceClass: "illuminance", unit: "lx", stateClass: "measurement"},
+	{attribute: "currentActivePower", name: "Power", deviceClass: "power", unit: "W", stateClass: "measurement"},
+	{attribute: "totalEnergyConsumed", name: "Energy", deviceClass: "energy", unit: "kWh", stateClass: "total_increasing"},
+	{attribute: "batteryPercentage", name: "Battery", deviceClass: "battery", unit: "%", stateClass: "measurement", diagnostic: true},
+}
+
+var binarySensorDefinitions = []sensorDefinition{
+	{attribute: "isDetected", name: "Motion", deviceClass: "motion"},
+	{attribute: "isOpen", name: "Opening", deviceClass: "opening"},
+	{attribute: "waterLeakDetected", name: "Water leak", deviceClass: "moisture"},
+}
+
+// publishDiscovery publishes the Home Assistant discovery configs of the device. Configs are only published if they
+// changed, configs of entities the device no longer provides are removed.
+func (b *MQTTBridge) publishDiscovery(device *client.Device, stateTopic, setTopic string) {
+	entities := b.discoveryEntities(device, stateTopic, setTopic)
+	configs := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		payload, err := json.Marshal(entity.config)
+		if err != nil {
+			b.options.Logger.Warn("could not encode discovery config", "device", device.ID, "error", err)
+			continue
+		}
+		configs[b.discoveryTopic(device, entity)] = string(payload)
+	}
+
+	b.mutex.Lock()
+	previousConfigs := b.discoveryConfigs[device.ID]
+	b.discoveryConfigs[device.ID] = configs
+	b.mutex.Unlock()
+
+	for topic := range previousConfigs {
+		if _, found := configs[topic]; !found {
+			b.publish(topic, true, nil)
+		}
+	}
+	for topic, payload := range configs {
+		if previousConfigs[topic] != payload {
+			b.publish(topic, true, []byte(payload))
+		}
+	}
+}
+
+// removeDiscovery removes the entities of the device from Home Assistant.
+func (b *MQTTBridge) removeDiscovery(deviceID string) {
+	b.mutex.Lock()
+	configs := b.discoveryConfigs[deviceID]
+	delete(b.discoveryConfigs, deviceID)
+	b.mutex.Unlock()
+
+	for topic := range configs {
+		b.publish(topic, true, nil)
+	}
+}
+
+// handleHomeAssistantStatus publishes all discovery configs again when Home Assistant is started, so entities are
+// restored even if the broker does not retain the configs.
+func (b *MQTTBridge) handleHomeAssistantStatus(_ mqtt.Client, message mqtt.Message) {
+	if string(message.Payload()) != PayloadOnline {
+		return
+	}
+	b.mutex.Lock()
+	configs := make(map[string]string)
+	for _, deviceConfigs := range b.discoveryConfigs {
+		maps.Copy(configs, deviceConfigs)
+	}
+	b.mutex.Unlock()
+
+	b.options.Logger.Info("Home Assistant started, publishing discovery configs", "entities", len(configs))
+	for topic, payload := range configs {
+		b.publish(topic, true, []byte(payload))
+	}
+}
+
+func (b *MQTTBridge) discoveryTopic(device *client.Device, entity discoveryEntity) string {
+	return fmt.Sprintf("%s/%s/dirigera_%s/%s/config", b.options.DiscoveryPrefix, entity.component, topicSegment(device.ID), entity.object)
+}
+
+// discoveryEntities maps the device to Home Assistant entities by its type and the available attributes.
+func (b *MQTTBridge) discoveryEntities(device *client.Device, stateTopic, setTopic string) []discoveryEntity {
+	var entities []discoveryEntity
+	entity := func(component, object string, config map[string]interface{}) {
+		config["unique_id"] = "dirigera_" + topicSegment(device.ID) + "_" + object
+		config["state_topic"] = stateTopic
+		config["availability"] = []map[string]string{
+			{"topic": b.availabilityTopic},
+			{"topic": stateTopic, "value_template": "{{ 'online' if value_json.isReachable else 'offline' }}"},
+		}
+		config["availability_mode"] = "all"
+		config["device"] = discoveryDevice(device)
+		entities = append(entities, discoveryEntity{component: component, object: object, config: config})
+	}
+	hasAttribute := func(name string) bool {
+		_, found := device.Attributes[name]
+		return found
+	}
+
+	switch device.Type {
+	case "light":
+		config := map[string]interface{}{
+			"name":                 nil,
+			"command_topic":        setTopic,
+			"payload_on":           `{"isOn":true}`,
+			"payload_off":          `{"isOn":false}`,
+			"state_value_template": `{{ '{"isOn":true}' if value_json.attributes.isOn else '{"isOn":false}' }}`,
+		}
+		if device.CanReceive("lightLevel") {
+			config["brightness_state_topic"] = stateTopic
+			config["brightness_value_template"] = "{{ value_json.attributes.lightLevel }}"
+			config["brightness_command_topic"] = setTopic
+			config["brightness_command_template"] = `{"lightLevel":{{ [value, 1] | max }}}`
+			config["brightness_scale"] = 100
+		}
+		if device.CanReceive("colorTemperature") {
+			config["color_temp_state_topic"] = stateTopic
+			config["color_temp_value_template"] = "{{ (1000000 / value_json.attributes.colorTemperature) | round(0) | int }}"
+			config["color_temp_command_topic"] = setTopic
+			config["color_temp_command_template"] = `{"colorTemperature":{{ (1000000 / value) | round(0) | int }}}`
+			// The coldest color temperature in Kelvin is the lowest in Mired
+			if maximum, isNumber := device.Attributes["colorTemperatureMax"].(float64); isNumber && maximum > 0 {
+				config["min_mireds"] = int(math.Round(1000000 / maximum))
+			}
+			if minimum, isNumber := device.Attributes["colorTemperatureMin"].(float64); isNumber && minimum > 0 {
+				config["max_mireds"] = int(math.Round(1000000 / minimum))
+			}
+		}
+		if device.CanReceive("colorHue") && device.CanReceive("colorSaturation") {
+			config["hs_state_topic"] = stateTopic
+			config["hs_value_template"] = "{{ value_json.attributes.colorHue }},{{ value_json.attributes.colorSaturation * 100 }}"
+			config["hs_command_topic"] = setTopic
+			config["hs_command_template"] = `{"colorHue":{{ hue }},"colorSaturation":{{ sat / 100 }}}`
+		}
+		entity("light", "light", config)
+	case "outlet":
+		entity("switch", "switch", map[string]interface{}{
+			"name":           nil,
+			"command_topic":  setTopic,
+			"payload_on":     `{"isOn":true}`,
+			"payload_off":    `{"isOn":false}`,
+			"value_template": "{{ value_json.attributes.isOn }}",
+			"state_on":       "True",
+			"state_off":      "False",
+		})
+	case "blinds":
+		// The hub uses 0 for open blinds, Home Assistant uses 100
+		entity("cover", "cover", map[string]interface{}{
+			"name":                  nil,
+			"device_class":          "blind",
+			"command_topic":         setTopic,
+			"payload_open":          `{"blindsTargetLevel":0}`,
+			"payload_close":         `{"blindsTargetLevel":100}`,
+			"payload_stop":          nil,
+			"value_template":        "{{ 'closed' if value_json.attributes.blindsCurrentLevel == 100 else 'open' }}",
+			"position_topic":        stateTopic,
+			"position_template":     "{{ 100 - value_json.attributes.blindsCurrentLevel }}",
+			"set_position_topic":    setTopic,
+			"set_position_template": `{"blindsTargetLevel":{{ 100 - position }}}`,
+		})
+	case "airPurifier":
+		entity("fan", "fan", map[string]interface{}{
+			"name":                         nil,
+			"command_topic":                setTopic,
+			"payload_on":                   `{"fanMode":"auto"}`,
+			"payload_off":                  `{"fanMode":"off"}`,
+			"state_value_template":         `{{ '{"fanMode":"off"}' if value_json.attributes.fanMode == 'off' else '{"fanMode":"auto"}' }}`,
+			"preset_modes":                 []string{"auto", "low", "medium", "high"},
+			"preset_mode_state_topic":      stateTopic,
+			"preset_mode_value_template":   "{{ value_json.attributes.fanMode }}",
+			"preset_mode_command_topic":    setTopic,
+			"preset_mode_command_template": `{"fanMode":"{{ value }}"}`,
+		})
+	}
+
+	for _, definition := range binarySensorDefinitions {
+		if !hasAttribute(definition.attribute) {
+			continue
+		}
+		entity("binary_sensor", topicSegment(definition.attribute), map[string]interface{}{
+			"name":           definition.name,
+			"device_class":   definition.deviceClass,
+			"value_template": fmt.Sprintf("{{ value_json.attributes.%s }}", definition.attribute),
+			"payload_on":     "True",
+			"payload_off":    "False",
+		})
+	}
+	for _, definition := range sensorDefinitions {
+		if !hasAttribute(definition.attribute) {
+			continue
+		}
+		config := map[string]interface{}{
+			"name":           definition.name,
+			"value_template": fmt.Sprintf("{{ value_json.attributes.%s }}", definition.attribute),
+			"state_class":    definition.stateClass,
+		}
+		if definition.deviceClass != "" {
+			config["device_class"] = definition.deviceClass
+		}
+		if definition.unit != "" {
+			config["unit_of_measurement"] = definition.unit
+		}
+		if definition.diagnostic {
+			config["entity_category"] = "diagnostic"
+		}
+		entity("sensor", topicSegment(definition.attribute), config)
+	}
+
+	return entities
+}
+
+// discoveryDevice groups the entities of a device in Home Assistant and assigns the device to the area of its room.
+func discoveryDevice(device *client.Device) map[string]interface{} {
+	result := map[string]interface{}{
+		"identifiers": []string{"dirigera_" + device.ID},
+		"name":        deviceName(device),
+	}
+	for attribute, key := range map[string]string{
+		"manufacturer":    "manufacturer",
+		"model":           "model",
+		"firmwareVersion": "sw_version",
+		"hardwareVersion": "hw_version",
+		"serialNumber":    "serial_number",
+	} {
+		if value, isString := device.Attributes[attribute].(string); isString && value != "" {
+			result[key] = value
+		}
+	}
+	if device.Room.Name != "" {
+		result["suggested_area"] = device.Room.Name
+	}
+
+	return result
+}
@@ -0,0 +1,480 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const (
+	DefaultStateTopic        = "dirigera/{{.Hub}}/{{.Room}}/{{.Device}}/state"
+	DefaultSetTopic          = "dirigera/{{.Hub}}/{{.Room}}/{{.Device}}/set"
+	DefaultAvailabilityTopic = "dirigera/{{.Hub}}/availability"
+
+	PayloadOnline  = "online"
+	PayloadOffline = "offline"
+
+	connectTimeout    = 30 * time.Second
+	disconnectQuiesce = 250
+)
+
+// MQTTOptions configures the connection to the broker and the topics used by MQTTBridge. Topics are templates
+// using the fields of TopicData, e.g. dirigera/{{.Hub}}/{{.Room}}/{{.Device}}/state.
+type MQTTOptions struct {
+	Broker            string
+	ClientID          string
+	Username          string
+	Password          string
+	TLSConfig         *tls.Config
+	QoS               byte
+	HubName           string
+	StateTopic        string
+	SetTopic          string
+	AvailabilityTopic string
+	// HomeAssistantDiscovery publishes discovery configs, so Home Assistant creates entities for the devices.
+	HomeAssistantDiscovery bool
+	DiscoveryPrefix        string
+	Logger                 *slog.Logger
+}
+
+// TopicData contains the values available in topic templates. Hub, Room and Device are converted to lowercase
+// topic segments without spaces and MQTT wildcards, e.g. "Living Room" becomes living_room.
+type TopicData struct {
+	Hub      string
+	Room     string
+	Device   string
+	DeviceID string
+	Type     string
+}
+
+// DeviceState is the retained payload published to the state topic of a device.
+type DeviceState struct {
+	ID           string                 `json:"id"`
+	Name         string                 `json:"name"`
+	Type         string                 `json:"type"`
+	DetailedType string                 `json:"deviceType"`
+	Room         string                 `json:"room"`
+	IsReachable  bool                   `json:"isReachable"`
+	LastSeen     time.Time              `json:"lastSeen"`
+	Attributes   map[string]interface{} `json:"attributes"`
+}
+
+// MQTTBridge publishes the state of all devices of a hub to retained MQTT topics and writes attributes received
+// on the set topics back to the devices.
+type MQTTBridge struct {
+	dirigeraClient       client.Client
+	options              MQTTOptions
+	stateTemplate        *template.Template
+	setTemplate          *template.Template
+	availabilityTemplate *template.Template
+	mqttClient           mqtt.Client
+	availabilityTopic    string
+	hub                  string
+	devices              *client.DeviceCache
+	mutex                sync.Mutex
+	stateTopics          map[string]string
+	setTopics            map[string]string
+	discoveryConfigs     map[string]map[string]string
+}
+
+// NewMQTTBridge creates a new MQTTBridge for the hub. Empty topics in the options are replaced by the defaults.
+func NewMQTTBridge(dirigeraClient client.Client, options MQTTOptions) (*MQTTBridge, error) {
+	if options.Broker == "" {
+		return nil, fmt.Errorf("no broker specified")
+	}
+	if options.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d: must be 0, 1 or 2", options.QoS)
+	}
+	if options.StateTopic == "" {
+		options.StateTopic = DefaultStateTopic
+	}
+	if options.SetTopic == "" {
+		options.SetTopic = DefaultSetTopic
+	}
+	if options.AvailabilityTopic == "" {
+		options.AvailabilityTopic = DefaultAvailabilityTopic
+	}
+	if options.DiscoveryPrefix == "" {
+		options.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	b := &MQTTBridge{
+		dirigeraClient:   dirigeraClient,
+		options:          options,
+		devices:          client.NewDeviceCache(dirigeraClient, options.Logger),
+		stateTopics:      make(map[string]string),
+		setTopics:        make(map[string]string),
+		discoveryConfigs: make(map[string]map[string]string),
+	}
+	var err error
+	if b.stateTemplate, err = parseTopicTemplate("state", options.StateTopic); err != nil {
+		return nil, err
+	}
+	if b.setTemplate, err = parseTopicTemplate("set", options.SetTopic); err != nil {
+		return nil, err
+	}
+	if b.availabilityTemplate, err = parseTopicTemplate("availability", options.AvailabilityTopic); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Run connects to the broker, publishes the state of all devices and keeps them in sync with the events of the hub
+// until the context is cancelled. The availability topic is set to online while running and to offline afterward,
+// also by the last will of the broker connection if the bridge terminates unexpectedly.
+func (b *MQTTBridge) Run(ctx context.Context) error {
+	b.hub = b.options.HubName
+	if b.hub == "" {
+		hub, err := b.dirigeraClient.GetHub()
+		if err != nil {
+			return fmt.Errorf("could not get hub: %w", err)
+		}
+		b.hub = hub.Name
+		if b.hub == "" {
+			b.hub = hub.ID
+		}
+	}
+	availabilityTopic, err := renderTopic(b.availabilityTemplate, TopicData{Hub: topicSegment(b.hub)})
+	if err != nil {
+		return err
+	}
+	b.availabilityTopic = availabilityTopic
+
+	devices, err := b.devices.Load()
+	if err != nil {
+		return err
+	}
+
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(b.options.Broker).
+		SetClientID(b.options.ClientID).
+		SetUsername(b.options.Username).
+		SetPassword(b.options.Password).
+		SetTLSConfig(b.options.TLSConfig).
+		SetWill(b.availabilityTopic, PayloadOffline, b.options.QoS, true).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			b.options.Logger.Warn("connection to broker lost", "broker", b.options.Broker, "error", err)
+		})
+	b.mqttClient = mqtt.NewClient(clientOptions)
+	token := b.mqttClient.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		return fmt.Errorf("timeout connecting to broker %s", b.options.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("could not connect to broker %s: %w", b.options.Broker, err)
+	}
+	defer func() {
+		b.publish(b.availabilityTopic, true, []byte(PayloadOffline)).Wait()
+		b.mqttClient.Disconnect(disconnectQuiesce)
+	}()
+
+	for _, device := range devices {
+		b.updateDevice(device)
+	}
+
+	return client.RunEvents(ctx, b.dirigeraClient, b.handleEvent, b.resync, b.options.Logger)
+}
+
+// resync publishes the current state of all devices after the event connection was lost and removes the topics of
+// devices removed in the meantime.
+func (b *MQTTBridge) resync() {
+	devices, err := b.devices.Load()
+	if err != nil {
+		b.options.Logger.Warn("could not read devices", "error", err)
+		return
+	}
+	known := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		known[device.ID] = true
+		b.updateDevice(device)
+	}
+	b.mutex.Lock()
+	var removed []string
+	for deviceID := range b.stateTopics {
+		if !known[deviceID] {
+			removed = append(removed, deviceID)
+		}
+	}
+	b.mutex.Unlock()
+	for _, deviceID := range removed {
+		b.removeDevice(deviceID)
+	}
+}
+
+// onConnect announces the bridge and subscribes the set topics again, because the session is not kept by the broker.
+func (b *MQTTBridge) onConnect(mqttClient mqtt.Client) {
+	b.options.Logger.Info("connected to broker", "broker", b.options.Broker)
+	b.publish(b.availabilityTopic, true, []byte(PayloadOnline))
+
+	b.mutex.Lock()
+	filters := make(map[string]byte, len(b.setTopics))
+	for topic := range b.setTopics {
+		filters[topic] = b.options.QoS
+	}
+	b.mutex.Unlock()
+	if len(filters) > 0 {
+		b.wait(mqttClient.SubscribeMultiple(filters, b.handleSet), "subscribe set topics")
+	}
+	if b.options.HomeAssistantDiscovery {
+		statusTopic := b.options.DiscoveryPrefix + "/status"
+		b.wait(mqttClient.Subscribe(statusTopic, b.options.QoS, b.handleHomeAssistantStatus), "subscribe "+statusTopic)
+	}
+}
+
+func (b *MQTTBridge) handleEvent(event client.Event) {
+	switch event.Type {
+	case "deviceStateChanged", "deviceAdded", "deviceConfigurationChanged":
+		if _, device := b.devices.Apply(event); device != nil {
+			b.updateDevice(device)
+		}
+	case "deviceRemoved":
+		b.devices.Apply(event)
+		b.removeDevice(event.Device.ID)
+	}
+}
+
+// updateDevice publishes the state of the device and moves its topics if the name or room of the device changed.
+func (b *MQTTBridge) updateDevice(device *client.Device) {
+	data := b.topicData(device)
+	stateTopic, err := renderTopic(b.stateTemplate, data)
+	if err != nil {
+		b.options.Logger.Warn("could not render state topic", "device", device.ID, "error", err)
+		return
+	}
+	setTopic, err := renderTopic(b.setTemplate, data)
+	if err != nil {
+		b.options.Logger.Warn("could not render set topic", "device", device.ID, "error", err)
+		return
+	}
+	payload, err := json.Marshal(newDeviceState(device))
+	if err != nil {
+		b.options.Logger.Warn("could not encode state", "device", device.ID, "error", err)
+		return
+	}
+
+	b.mutex.Lock()
+	previousStateTopic := b.stateTopics[device.ID]
+	b.stateTopics[device.ID] = stateTopic
+	previousSetTopic := ""
+	for topic, deviceID := range b.setTopics {
+		if deviceID == device.ID {
+			previousSetTopic = topic
+		}
+	}
+	if previousSetTopic != setTopic {
+		delete(b.setTopics, previousSetTopic)
+		b.setTopics[setTopic] = device.ID
+	}
+	b.mutex.Unlock()
+
+	if previousStateTopic != "" && previousStateTopic != stateTopic {
+		// Remove the retained state of the previous topic
+		b.publish(previousStateTopic, true, nil)
+	}
+	if previousSetTopic != setTopic {
+		if previousSetTopic != "" {
+			b.wait(b.mqttClient.Unsubscribe(previousSetTopic), "unsubscribe "+previousSetTopic)
+		}
+		b.wait(b.mqttClient.Subscribe(setTopic, b.options.QoS, b.handleSet), "subscribe "+setTopic)
+	}
+	b.publish(stateTopic, true, payload)
+	if b.options.HomeAssistantDiscovery {
+		b.publishDiscovery(device, stateTopic, setTopic)
+	}
+}
+
+func (b *MQTTBridge) removeDevice(deviceID string) {
+	b.mutex.Lock()
+	stateTopic := b.stateTopics[deviceID]
+	setTopic := ""
+	for topic, id := range b.setTopics {
+		if id == deviceID {
+			setTopic = topic
+		}
+	}
+	delete(b.stateTopics, deviceID)
+	delete(b.setTopics, setTopic)
+	b.mutex.Unlock()
+
+	if stateTopic != "" {
+		b.publish(stateTopic, true, nil)
+	}
+	if setTopic != "" {
+		b.wait(b.mqttClient.Unsubscribe(setTopic), "unsubscribe "+setTopic)
+	}
+	if b.options.HomeAssistantDiscovery {
+		b.removeDiscovery(deviceID)
+	}
+}
+
+// handleSet writes the attributes of a JSON object received on a set topic to the device. The optional field
+// transitionTime defines the duration of the change in milliseconds.
+func (b *MQTTBridge) handleSet(_ mqtt.Client, message mqtt.Message) {
+	b.mutex.Lock()
+	deviceID, found := b.setTopics[message.Topic()]
+	b.mutex.Unlock()
+	device := b.devices.Get(deviceID)
+	if !found || device == nil {
+		return
+	}
+
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(message.Payload(), &attributes); err != nil {
+		b.options.Logger.Warn("invalid payload on set topic, expected a JSON object", "topic", message.Topic(), "error", err)
+		return
+	}
+	var transitionTime time.Duration
+	if value, found := attributes["transitionTime"]; found {
+		milliseconds, isNumber := value.(float64)
+		if !isNumber || milliseconds < 0 {
+			b.options.Logger.Warn("invalid transitionTime on set topic, expected milliseconds", "topic", message.Topic())
+			return
+		}
+		transitionTime = time.Duration(milliseconds) * time.Millisecond
+		delete(attributes, "transitionTime")
+	}
+	for name := range attributes {
+		if !device.CanReceive(name) {
+			b.options.Logger.Warn("attribute can not be set", "device", deviceID, "attribute", name, "supported", device.Capabilities.CanReceive)
+			return
+		}
+	}
+	if len(attributes) == 0 {
+		return
+	}
+	if err := b.dirigeraClient.SetDeviceAttributes(deviceID, attributes, transitionTime); err != nil {
+		b.options.Logger.Warn("could not set attributes", "device", deviceID, "error", err)
+	}
+}
+
+// topicData returns the values for the topic templates. Devices with the same name in the same room are
+// distinguished by their ID, so their topics do not collide.
+func (b *MQTTBridge) topicData(device *client.Device) TopicData {
+	data := TopicData{
+		Hub:      topicSegment(b.hub),
+		Room:     topicSegment(roomName(device)),
+		Device:   topicSegment(deviceName(device)),
+		DeviceID: device.ID,
+		Type:     device.Type,
+	}
+
+	for _, other := range b.devices.Devices() {
+		if other.ID != device.ID && topicSegment(roomName(other)) == data.Room && topicSegment(deviceName(other)) == data.Device {
+			data.Device = data.Device + "_" + topicSegment(device.ID)
+			break
+		}
+	}
+
+	return data
+}
+
+// publish sends the message without blocking the caller, errors are logged.
+func (b *MQTTBridge) publish(topic string, retained bool, payload []byte) mqtt.Token {
+	token := b.mqttClient.Publish(topic, b.options.QoS, retained, payload)
+	go b.wait(token, "publish "+topic)
+
+	return token
+}
+
+func (b *MQTTBridge) wait(token mqtt.Token, operation string) {
+	if !token.WaitTimeout(connectTimeout) {
+		b.options.Logger.Warn("timeout waiting for broker", "operation", operation)
+		return
+	}
+	if err := token.Error(); err != nil {
+		b.options.Logger.Warn("broker operation failed", "operation", operation, "error", err)
+	}
+}
+
+func newDeviceState(device *client.Device) DeviceState {
+	return DeviceState{
+		ID:           device.ID,
+		Name:         deviceName(device),
+		Type:         device.Type,
+		DetailedType: device.DetailedType,
+		Room:         device.Room.Name,
+		IsReachable:  device.IsReachable,
+		LastSeen:     device.LastSeen,
+		Attributes:   device.Attributes,
+	}
+}
+
+func deviceName(device *client.Device) string {
+	if name := device.CustomName(); name != "" {
+		return name
+	}
+
+	return device.ID
+}
+
+func roomName(device *client.Device) string {
+	if device.Room.Name != "" {
+		return device.Room.Name
+	}
+
+	return "unassigned"
+}
+
+func parseTopicTemplate(name, topic string) (*template.Template, error) {
+	topicTemplate, err := template.New(name).Option("missingkey=error").Parse(topic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s topic %s: %w", name, topic, err)
+	}
+	if _, err := renderTopic(topicTemplate, TopicData{Hub: "hub", Room: "room", Device: "device", DeviceID: "id", Type: "type"}); err != nil {
+		return nil, fmt.Errorf("invalid %s topic %s: %w", name, topic, err)
+	}
+
+	return topicTemplate, nil
+}
+
+func renderTopic(topicTemplate *template.Template, data TopicData) (string, error) {
+	topic := &bytes.Buffer{}
+	if err := topicTemplate.Execute(topic, data); err != nil {
+		return "", fmt.Errorf("error rendering topic: %w", err)
+	}
+	if topic.Len() == 0 || strings.ContainsAny(topic.String(), "+#") {
+		return "", fmt.Errorf("invalid topic %q: must not be empty or contain wildcards", topic.String())
+	}
+
+	return topic.String(), nil
+}
+
+// topicSegment converts a name to a lowercase topic segment, e.g. "Living Room" to living_room.
+func topicSegment(name string) string {
+	segment := &strings.Builder{}
+	separator := false
+	for _, character := range strings.ToLower(name) {
+		if unicode.IsLetter(character) || unicode.IsDigit(character) || character == '-' {
+			if separator && segment.Len() > 0 {
+				segment.WriteRune('_')
+			}
+			segment.WriteRune(character)
+			separator = false
+			continue
+		}
+		separator = true
+	}
+	if segment.Len() == 0 {
+		return "_"
+	}
+
+	return segment.String()
+}
@@ -0,0 +1,244 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const (
+	testTimeout           = 5 * time.Second
+	testClientID          = "dirigera-bridge-test"
+	testStateTopic        = "dirigera/home/kitchen/ceiling/state"
+	testSetTopic          = "dirigera/home/kitchen/ceiling/set"
+	testAvailabilityTopic = "dirigera/home/availability"
+)
+
+// fakeClient serves a single light and records the attributes set by the bridge.
+type fakeClient struct {
+	client.Client
+	devices    []*client.Device
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	attributes chan map[string]interface{}
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		devices: []*client.Device{{
+			ID:          "light-1",
+			Type:        "light",
+			IsReachable: true,
+			Room:        client.Room{ID: "room-1", Name: "Kitchen"},
+			Attributes:  map[string]interface{}{"customName": "Ceiling", "isOn": false, "lightLevel": 50.0},
+			Capabilities: client.Capabilities{
+				CanReceive: []string{"isOn", "lightLevel"},
+			},
+		}},
+		stopped:    make(chan struct{}),
+		attributes: make(chan map[string]interface{}, 1),
+	}
+}
+
+func (f *fakeClient) ListDevices() ([]*client.Device, error) {
+	return f.devices, nil
+}
+
+func (f *fakeClient) GetDevice(deviceID string) (*client.Device, error) {
+	for _, device := range f.devices {
+		if device.ID == deviceID {
+			return device, nil
+		}
+	}
+
+	return nil, fmt.Errorf("device %s not found", deviceID)
+}
+
+func (f *fakeClient) SetDeviceAttributes(deviceID string, attributes map[string]interface{}, _ time.Duration) error {
+	if deviceID != f.devices[0].ID {
+		return fmt.Errorf("device %s not found", deviceID)
+	}
+	f.attributes <- attributes
+
+	return nil
+}
+
+func (f *fakeClient) RegisterEventHandler(client.EventHandler, ...string) {}
+
+func (f *fakeClient) RegisterConnectionHandler(client.ConnectionHandler) {}
+
+func (f *fakeClient) ListenForEvents() error {
+	<-f.stopped
+	return nil
+}
+
+func (f *fakeClient) StopEventListening() error {
+	f.stopOnce.Do(func() {
+		close(f.stopped)
+	})
+
+	return nil
+}
+
+// startBroker starts an MQTT broker on a random local port and returns it with its address.
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{Logger: slog.New(slog.DiscardHandler)})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("could not add auth hook: %v", err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatalf("could not add listener: %v", err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return server, "tcp://" + listener.Address()
+}
+
+// startBridge runs a bridge for the client until the test ends.
+func startBridge(t *testing.T, broker string, dirigeraClient client.Client) {
+	t.Helper()
+	bridge, err := NewMQTTBridge(dirigeraClient, MQTTOptions{
+		Broker:   broker,
+		ClientID: testClientID,
+		QoS:      1,
+		HubName:  "Home",
+	})
+	if err != nil {
+		t.Fatalf("could not create bridge: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- bridge.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("bridge stopped with error: %v", err)
+		}
+	})
+}
+
+// subscribe connects a client to the broker and returns the messages received on the topic.
+func subscribe(t *testing.T, broker, clientID, topic string) <-chan mqtt.Message {
+	t.Helper()
+	subscriber := connect(t, broker, clientID)
+	messages := make(chan mqtt.Message, 16)
+	token := subscriber.Subscribe(topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+		messages <- message
+	})
+	if !token.WaitTimeout(testTimeout) || token.Error() != nil {
+		t.Fatalf("could not subscribe %s: %v", topic, token.Error())
+	}
+
+	return messages
+}
+
+func connect(t *testing.T, broker, clientID string) mqtt.Client {
+	t.Helper()
+	mqttClient := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID(clientID))
+	token := mqttClient.Connect()
+	if !token.WaitTimeout(testTimeout) || token.Error() != nil {
+		t.Fatalf("could not connect to broker: %v", token.Error())
+	}
+	t.Cleanup(func() {
+		mqttClient.Disconnect(0)
+	})
+
+	return mqttClient
+}
+
+// receive returns the next message with the payload or fails the test after the timeout.
+func receive(t *testing.T, messages <-chan mqtt.Message, payload string) mqtt.Message {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case message := <-messages:
+			if payload == "" || string(message.Payload()) == payload {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no message %q received", payload)
+		}
+	}
+}
+
+func TestMQTTBridgePublishesRetainedState(t *testing.T) {
+	_, broker := startBroker(t)
+	updates := subscribe(t, broker, "observer", testStateTopic)
+	startBridge(t, broker, newFakeClient())
+	receive(t, updates, "")
+
+	// A client connecting later still receives the state
+	message := receive(t, subscribe(t, broker, "late-observer", testStateTopic), "")
+	if !message.Retained() {
+		t.Errorf("state of %s not retained", testStateTopic)
+	}
+	var state DeviceState
+	if err := json.Unmarshal(message.Payload(), &state); err != nil {
+		t.Fatalf("invalid state %s: %v", message.Payload(), err)
+	}
+	if state.ID != "light-1" || state.Name != "Ceiling" || state.Room != "Kitchen" || !state.IsReachable {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if state.Attributes["isOn"] != false || state.Attributes["lightLevel"] != 50.0 {
+		t.Errorf("unexpected attributes %v", state.Attributes)
+	}
+}
+
+func TestMQTTBridgeSetsAttributes(t *testing.T) {
+	_, broker := startBroker(t)
+	dirigeraClient := newFakeClient()
+	updates := subscribe(t, broker, "observer", testStateTopic)
+	startBridge(t, broker, dirigeraClient)
+	receive(t, updates, "")
+
+	publisher := connect(t, broker, "publisher")
+	// Attributes the device can not receive are rejected as a whole
+	publisher.Publish(testSetTopic, 1, false, `{"isOn":true,"colorHue":120}`).Wait()
+	publisher.Publish(testSetTopic, 1, false, `{"isOn":true,"lightLevel":80,"transitionTime":500}`).Wait()
+
+	select {
+	case attributes := <-dirigeraClient.attributes:
+		if len(attributes) != 2 || attributes["isOn"] != true || attributes["lightLevel"] != 80.0 {
+			t.Errorf("unexpected attributes %v", attributes)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("attributes not set")
+	}
+}
+
+func TestMQTTBridgeLastWill(t *testing.T) {
+	server, broker := startBroker(t)
+	availability := subscribe(t, broker, "observer", testAvailabilityTopic)
+	startBridge(t, broker, newFakeClient())
+	receive(t, availability, PayloadOnline)
+
+	// Drop the connection of the bridge without a disconnect, like a crashed bridge
+	bridgeClient, found := server.Clients.Get(testClientID)
+	if !found {
+		t.Fatalf("bridge %s not connected", testClientID)
+	}
+	bridgeClient.Stop(errors.New("connection dropped"))
+
+	receive(t, availability, PayloadOffline)
+}
@@ -7,12 +7,15 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +30,8 @@ const (
 type Authorization struct {
 	AccessToken    string
 	TLSFingerprint string
+	// PinnedFingerprints are trusted in addition to TLSFingerprint, e.g. during the rotation of the hub certificate.
+	PinnedFingerprints []string
 }
 
 type authorizationRoundTripper struct {
@@ -47,12 +52,88 @@ func NewAuthorization(accessToken, tlsFingerprint string) Authorization {
 	}
 }
 
+type AuthorizeStatus string
+
+const (
+	AuthorizeWaitingForButton AuthorizeStatus = "waitingForButton"
+	AuthorizeHubRejected      AuthorizeStatus = "hubRejected"
+	AuthorizeNetworkError     AuthorizeStatus = "networkError"
+	AuthorizeCompleted        AuthorizeStatus = "completed"
+)
+
+// AuthorizeProgress is reported to the progress callback of AuthorizeWithOptions after every attempt.
+// Err contains the reason for the status AuthorizeHubRejected and AuthorizeNetworkError.
+type AuthorizeProgress struct {
+	Status  AuthorizeStatus
+	Attempt int
+	Elapsed time.Duration
+	Err     error
+}
+
+// AuthorizeOptions configures AuthorizeWithOptions. Timeout and Interval default to 60 and 2 seconds.
+type AuthorizeOptions struct {
+	ClientName string
+	Timeout    time.Duration
+	Interval   time.Duration
+	Progress   func(progress AuthorizeProgress)
+	// Logger receives a debug message for every attempt, nothing is logged if not set.
+	Logger *slog.Logger
+}
+
+// TokenError is returned when the hub does not issue an access token.
+type TokenError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *TokenError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("hub responded with status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("hub responded with status code %d: %s", e.StatusCode, e.Reason)
+}
+
 // Authorize registers a new user in the IKEA Smart-Home hub.
 // The process includes pressing the button on the hub to confirm the registration.
 // The methods startAuthFunc and runningAuthFunc provided as parameters are called during this process
 // to keep the user informed in interactive applications.
 func Authorize(address string, port int, clientName string, startAuthFunc, runningAuthFunc func()) (Authorization, error) {
+	return AuthorizeWithOptions(context.Background(), address, port, AuthorizeOptions{
+		ClientName: clientName,
+		Progress: func(progress AuthorizeProgress) {
+			if progress.Attempt == 0 {
+				startAuthFunc()
+			} else if progress.Status != AuthorizeCompleted {
+				runningAuthFunc()
+			}
+		},
+	})
+}
+
+// AuthorizeWithOptions registers a new user in the IKEA Smart-Home hub like Authorize, but can be cancelled by the
+// context and reports the status of every attempt to the progress callback. The callback is called with attempt 0
+// as soon as the button on the hub needs to be pressed.
+func AuthorizeWithOptions(ctx context.Context, address string, port int, options AuthorizeOptions) (Authorization, error) {
 	authorization := Authorization{}
+	if options.Timeout <= 0 {
+		options.Timeout = authTimeout
+	}
+	if options.Interval <= 0 {
+		options.Interval = authCheckInterval
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	report := options.Progress
+	if report == nil {
+		report = func(AuthorizeProgress) {}
+	}
+	progress := func(progress AuthorizeProgress) {
+		logger.Debug("authorization progress", "address", address, "status", progress.Status, "attempt", progress.Attempt,
+			"elapsed", progress.Elapsed, "error", progress.Err)
+		report(progress)
+	}
 	verifier := generateCodeVerifier(codeVerifierLength)
 	challenge := getCodeChallenge(verifier)
 
@@ -65,46 +146,80 @@ func Authorize(address string, port int, clientName string, startAuthFunc, runni
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
 	defer cancel()
 
-	ticker := time.NewTicker(authCheckInterval)
+	ticker := time.NewTicker(options.Interval)
 	defer ticker.Stop()
 
-	authCode, err := getAuthCode(httpClient, address, port, challenge)
+	start := time.Now()
+	authCode, err := getAuthCode(ctx, httpClient, address, port, challenge)
 	if err != nil {
 		return authorization, err
 	}
-	startAuthFunc()
-	for {
+	progress(AuthorizeProgress{Status: AuthorizeWaitingForButton, Elapsed: time.Since(start)})
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
 		select {
 		case <-ctx.Done():
-			return authorization, fmt.Errorf("authorization process timed out")
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if lastErr != nil {
+					return authorization, fmt.Errorf("authorization process timed out: %w", lastErr)
+				}
+				return authorization, fmt.Errorf("authorization process timed out")
+			}
+			return authorization, fmt.Errorf("authorization process cancelled: %w", ctx.Err())
 		case <-ticker.C:
-			token, err := getAccessToken(httpClient, address, port, clientName, verifier, authCode)
-			if err == nil && token != "" {
+			token, err := getAccessToken(ctx, httpClient, address, port, options.ClientName, verifier, authCode)
+			status := AuthorizeWaitingForButton
+			var tokenErr *TokenError
+			switch {
+			case err == nil && token != "":
 				authorization.AccessToken = token
+				progress(AuthorizeProgress{Status: AuthorizeCompleted, Attempt: attempt, Elapsed: time.Since(start)})
 				return authorization, nil
+			case errors.As(err, &tokenErr):
+				if tokenErr.StatusCode != http.StatusForbidden {
+					status = AuthorizeHubRejected
+				}
+			case err != nil:
+				if ctx.Err() != nil {
+					continue
+				}
+				status = AuthorizeNetworkError
+			}
+			if status != AuthorizeWaitingForButton {
+				lastErr = err
 			}
-			runningAuthFunc()
+			progress(AuthorizeProgress{Status: status, Attempt: attempt, Elapsed: time.Since(start), Err: err})
 		}
 	}
 }
 
-func getAuthCode(httpClient *http.Client, address string, port int, codeChallenge string) (string, error) {
-	authURL := fmt.Sprintf("https://%s:%d/v1/oauth/authorize", address, port)
+func getAuthCode(ctx context.Context, httpClient *http.Client, address string, port int, codeChallenge string) (string, error) {
+	authURL := fmt.Sprintf("https://%s/v1/oauth/authorize", net.JoinHostPort(address, strconv.Itoa(port)))
 	params := url.Values{}
 	params.Set("response_type", "code")
 	params.Set("audience", "homesmart.local")
 	params.Set("code_challenge", codeChallenge)
 	params.Set("code_challenge_method", "S256")
 
-	resp, err := httpClient.Get(fmt.Sprintf("%s?%s", authURL, params.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s?%s", authURL, params.Encode()), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &TokenError{StatusCode: resp.StatusCode, Reason: extractReason(body)}
+	}
+
 	var result struct {
 		Code string `json:"code"`
 	}
@@ -114,15 +229,15 @@ func getAuthCode(httpClient *http.Client, address string, port int, codeChalleng
 	return result.Code, nil
 }
 
-func getAccessToken(httpClient *http.Client, address string, port int, clientName, codeVerifier, authCode string) (string, error) {
-	tokenURL := fmt.Sprintf("https://%s:%d/v1/oauth/token", address, port)
+func getAccessToken(ctx context.Context, httpClient *http.Client, address string, port int, clientName, codeVerifier, authCode string) (string, error) {
+	tokenURL := fmt.Sprintf("https://%s/v1/oauth/token", net.JoinHostPort(address, strconv.Itoa(port)))
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", authCode)
 	data.Set("code_verifier", codeVerifier)
 	data.Set("name", clientName)
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -134,12 +249,12 @@ func getAccessToken(httpClient *http.Client, address string, port int, clientNam
 	}
 	defer resp.Body.Close()
 
+	body, _ := io.ReadAll(resp.Body)
+
 	if resp.StatusCode != http.StatusOK {
-		return "", nil
+		return "", &TokenError{StatusCode: resp.StatusCode, Reason: extractReason(body)}
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-
 	var result struct {
 		AccessToken string `json:"access_token"`
 	}
@@ -150,6 +265,24 @@ func getAccessToken(httpClient *http.Client, address string, port int, clientNam
 	return result.AccessToken, nil
 }
 
+// extractReason returns the error message of a JSON error response or the plain response body.
+func extractReason(body []byte) string {
+	var result struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &result); err == nil {
+		if result.Error != "" {
+			return result.Error
+		}
+		if result.Message != "" {
+			return result.Message
+		}
+	}
+
+	return strings.TrimSpace(string(body))
+}
+
 func normalizeFingerprint(opensslFP string) string {
 	parts := strings.SplitN(opensslFP, "=", 2)
 	fingerprint := parts[len(parts)-1]
@@ -185,19 +318,18 @@ func fingerprintVerifier(authorization *Authorization, autoTrust bool) func(rawC
 			return fmt.Errorf("error parsing certificate: %v", err)
 		}
 
-		hash := sha256.Sum256(cert.Raw)
-		fingerprint := hex.EncodeToString(hash[:])
+		info := NewCertificateInfo(cert)
 
-		if authorization.TLSFingerprint == "" {
+		if authorization.TLSFingerprint == "" && len(authorization.PinnedFingerprints) == 0 {
 			if autoTrust {
-				authorization.TLSFingerprint = fingerprint
+				authorization.TLSFingerprint = info.Fingerprint
 			} else {
 				return fmt.Errorf("no certificate in authorization")
 			}
 		}
 
-		if fingerprint != authorization.TLSFingerprint {
-			return fmt.Errorf("fingerprint does not match: %s", fingerprint)
+		if !authorization.Trusts(info.Fingerprint) {
+			return &FingerprintMismatchError{Certificate: info}
 		}
 
 		return nil
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DeviceCache keeps the state of the devices of a hub up to date from its events, so long-running commands do not
+// have to read devices for every event.
+type DeviceCache struct {
+	dirigeraClient Client
+	logger         *slog.Logger
+	mutex          sync.RWMutex
+	devices        map[string]*Device
+}
+
+// NewDeviceCache creates an empty cache for the devices of the hub. The logger may be nil.
+func NewDeviceCache(dirigeraClient Client, logger *slog.Logger) *DeviceCache {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &DeviceCache{
+		dirigeraClient: dirigeraClient,
+		logger:         logger,
+		devices:        make(map[string]*Device),
+	}
+}
+
+// Load replaces the cached devices by the current devices of the hub and returns them.
+func (c *DeviceCache) Load() ([]*Device, error) {
+	devices, err := c.dirigeraClient.ListDevices()
+	if err != nil {
+		return nil, fmt.Errorf("could not list devices: %w", err)
+	}
+	loaded := make(map[string]*Device, len(devices))
+	for _, device := range devices {
+		loaded[device.ID] = device
+	}
+	c.mutex.Lock()
+	c.devices = loaded
+	c.mutex.Unlock()
+
+	return devices, nil
+}
+
+// Apply updates the cache from the event and returns the state of the device before and after the event. Both are
+// the last known state for removed devices and previous is nil for devices unknown before the event. Events of
+// changed states only contain the changed attributes, so they are merged into the known state. Added, reconfigured
+// and unknown devices are read from the hub, device is nil if that fails.
+func (c *DeviceCache) Apply(event Event) (previous, device *Device) {
+	c.mutex.RLock()
+	previous = c.devices[event.Device.ID]
+	c.mutex.RUnlock()
+
+	switch {
+	case event.Type == "deviceRemoved":
+		c.mutex.Lock()
+		delete(c.devices, event.Device.ID)
+		c.mutex.Unlock()
+		return previous, previous
+	case event.Type == "deviceStateChanged" && previous != nil:
+		changed := *previous
+		changed.Attributes = maps.Clone(previous.Attributes)
+		if changed.Attributes == nil {
+			changed.Attributes = make(map[string]interface{})
+		}
+		maps.Copy(changed.Attributes, event.Device.Attributes)
+		if event.Reachability != nil {
+			changed.IsReachable = *event.Reachability
+		}
+		if !event.Device.LastSeen.IsZero() {
+			changed.LastSeen = event.Device.LastSeen
+		}
+		c.mutex.Lock()
+		c.devices[changed.ID] = &changed
+		c.mutex.Unlock()
+		return previous, &changed
+	case event.Type == "deviceStateChanged", event.Type == "deviceAdded", event.Type == "deviceConfigurationChanged":
+		refreshed, err := c.dirigeraClient.GetDevice(event.Device.ID)
+		if err != nil {
+			c.logger.Warn("could not read device", "device", event.Device.ID, "error", err)
+			return previous, nil
+		}
+		c.mutex.Lock()
+		c.devices[refreshed.ID] = refreshed
+		c.mutex.Unlock()
+		return previous, refreshed
+	default:
+		return previous, previous
+	}
+}
+
+// Reload reads the devices again like Load and only logs a failure. It fits as resync function of RunEvents.
+func (c *DeviceCache) Reload() {
+	if _, err := c.Load(); err != nil {
+		c.logger.Warn("could not read devices", "error", err)
+	}
+}
+
+// Get returns the device with the ID or nil if it is unknown.
+func (c *DeviceCache) Get(deviceID string) *Device {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.devices[deviceID]
+}
+
+// Find returns the device with the ID or name, names are compared case-insensitive. It returns nil if no device
+// matches.
+func (c *DeviceCache) Find(selector string) *Device {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if device, found := c.devices[selector]; found {
+		return device
+	}
+	for _, device := range c.devices {
+		if strings.EqualFold(selector, device.CustomName()) {
+			return device
+		}
+	}
+
+	return nil
+}
+
+// Devices returns all cached devices ordered by ID.
+func (c *DeviceCache) Devices() []*Device {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return slices.SortedFunc(maps.Values(c.devices), func(a, b *Device) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// RunEvents passes the events of the hub to the handler until the context is cancelled or listening fails. Events
+// are missed while the connection is lost, so resync is called when the connection is established again, before
+// further events are passed. resync may be nil.
+func RunEvents(ctx context.Context, dirigeraClient Client, handler EventHandler, resync func(), logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	connections := 0
+	dirigeraClient.RegisterConnectionHandler(func(connected bool, err error) {
+		if !connected {
+			return
+		}
+		connections++
+		if connections > 1 && resync != nil {
+			logger.Info("event connection established again, reading the current state")
+			resync()
+		}
+	})
+	dirigeraClient.RegisterEventHandler(handler)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- dirigeraClient.ListenForEvents()
+	}()
+
+	select {
+	case <-ctx.Done():
+		if err := dirigeraClient.StopEventListening(); err != nil {
+			logger.Warn("could not stop event listening", "error", err)
+		}
+		<-listening
+		return nil
+	case err := <-listening:
+		return fmt.Errorf("event listening stopped: %w", err)
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// CertificateInfo describes the TLS certificate presented by a hub.
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+// Expired returns true if the certificate is not valid at the specified time.
+func (i *CertificateInfo) Expired(now time.Time) bool {
+	return now.Before(i.NotBefore) || now.After(i.NotAfter)
+}
+
+// FingerprintMismatchError is returned when the hub presents a certificate that is not trusted by the authorization,
+// e.g. after a factory reset of the hub. The presented certificate can be trusted by updating the authorization.
+type FingerprintMismatchError struct {
+	Certificate *CertificateInfo
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf("fingerprint does not match: %s", e.Certificate.Fingerprint)
+}
+
+// Trusts returns true if the fingerprint is the TLS fingerprint or one of the pinned fingerprints of the authorization.
+func (a *Authorization) Trusts(fingerprint string) bool {
+	fingerprint = normalizeFingerprint(fingerprint)
+	if fingerprint == a.TLSFingerprint {
+		return true
+	}
+
+	return slices.ContainsFunc(a.PinnedFingerprints, func(pinned string) bool {
+		return normalizeFingerprint(pinned) == fingerprint
+	})
+}
+
+// FetchCertificate connects to the hub without verifying its certificate and returns the presented certificate.
+// The result must be compared with a trusted fingerprint before it is used to connect to the hub.
+func FetchCertificate(ctx context.Context, address string, port int) (*CertificateInfo, error) {
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+	connection, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to hub: %w", err)
+	}
+	defer func() {
+		_ = connection.Close()
+	}()
+
+	certificates := connection.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificates received")
+	}
+
+	return NewCertificateInfo(certificates[0]), nil
+}
+
+// NewCertificateInfo creates a new instance of CertificateInfo with the SHA-256 fingerprint of the certificate.
+func NewCertificateInfo(certificate *x509.Certificate) *CertificateInfo {
+	hash := sha256.Sum256(certificate.Raw)
+
+	return &CertificateInfo{
+		Subject:      certificate.Subject.String(),
+		Issuer:       certificate.Issuer.String(),
+		SerialNumber: certificate.SerialNumber.String(),
+		NotBefore:    certificate.NotBefore,
+		NotAfter:     certificate.NotAfter,
+		Fingerprint:  hex.EncodeToString(hash[:]),
+	}
+}
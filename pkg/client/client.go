@@ -1,27 +1,54 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"slices"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const eventLoopRetryInterval = 30 * time.Second
+
 type Event struct {
 	ID     string    `json:"id"`
 	Time   time.Time `json:"time"`
 	Source string    `json:"source"`
 	Type   string    `json:"type"`
 	Device Device    `json:"data"`
+	// Reachability is the reachability of the device if reported by the event. Events of changed states only contain
+	// the changed values, so Device.IsReachable is false if the reachability did not change.
+	Reachability *bool `json:"-"`
+}
+
+// UnmarshalJSON decodes the event and sets Reachability if the data of the event contains isReachable.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type plainEvent Event
+	if err := json.Unmarshal(data, (*plainEvent)(e)); err != nil {
+		return err
+	}
+	var reachability struct {
+		Data struct {
+			IsReachable *bool `json:"isReachable"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &reachability); err != nil {
+		return err
+	}
+	e.Reachability = reachability.Data.IsReachable
+
+	return nil
 }
 
 type Device struct {
@@ -32,9 +59,29 @@ type Device struct {
 	CreatedAt    time.Time              `json:"createdAt"`
 	LastSeen     time.Time              `json:"lastSeen"`
 	Attributes   map[string]interface{} `json:"attributes"`
+	Capabilities Capabilities           `json:"capabilities"`
 	Room         Room                   `json:"room"`
 }
 
+type Capabilities struct {
+	CanSend    []string `json:"canSend"`
+	CanReceive []string `json:"canReceive"`
+}
+
+// CustomName returns the name given to the device in the IKEA Home smart app or an empty string if not set.
+func (d *Device) CustomName() string {
+	if customName, isString := d.Attributes["customName"].(string); isString {
+		return customName
+	}
+
+	return ""
+}
+
+// CanReceive returns true if the device accepts changes of the specified attribute.
+func (d *Device) CanReceive(attribute string) bool {
+	return slices.Contains(d.Capabilities.CanReceive, attribute)
+}
+
 type Room struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -66,6 +113,29 @@ type Info struct {
 	Name string `json:"name"`
 }
 
+type FirmwareUpdateStatus struct {
+	FirmwareVersion string `json:"firmwareVersion"`
+	Status          string `json:"otaStatus"`
+	State           string `json:"otaState"`
+	Progress        int    `json:"otaProgress"`
+	Policy          string `json:"otaPolicy"`
+}
+
+// UpdateAvailable returns true if the hub found a firmware update that is not installed yet.
+func (s *FirmwareUpdateStatus) UpdateAvailable() bool {
+	return s.Status == "updateAvailable"
+}
+
+// InProgress returns true if the hub is checking for, downloading or installing a firmware update.
+func (s *FirmwareUpdateStatus) InProgress() bool {
+	switch s.State {
+	case "checkInProgress", "downloadInProgress", "updateInProgress":
+		return true
+	default:
+		return false
+	}
+}
+
 type User struct {
 	ID        string    `json:"uid"`
 	Name      string    `json:"name"`
@@ -82,16 +152,29 @@ type handlerRegistration struct {
 type Client interface {
 	ListDevices() ([]*Device, error)
 	GetDevice(deviceID string) (*Device, error)
+	SetDeviceAttributes(deviceID string, attributes map[string]interface{}, transitionTime time.Duration) error
 	GetHubStatus() (*Device, error)
+	GetHub() (*Hub, error)
+	SetHubName(name string) error
+	SetHubTimezone(timezone string) error
+	SetHubCoordinates(coordinates Coordinates) error
+	SetHubStatusLight(on bool) error
+	GetFirmwareUpdateStatus() (*FirmwareUpdateStatus, error)
+	CheckFirmwareUpdate() error
+	InstallFirmwareUpdate() error
 	ListRooms() ([]*Room, error)
 	GetRoom(roomID string) (*Room, error)
 	ListScenes() ([]*Scene, error)
 	GetScene(sceneID string) (*Scene, error)
+	TriggerScene(sceneID string) error
 	ListUsers() ([]*User, error)
 	GetUser(userID string) (*User, error)
 	GetCurrentUser() (*User, error)
+	UpdateCurrentUser(name string) error
 	DeleteUser(userID string) error
 	RegisterEventHandler(handler EventHandler, eventTypes ...string)
+	RegisterConnectionHandler(handler ConnectionHandler)
+	// Deprecated: Use the option WithLogger instead, SetEventLog logs as text to the writer.
 	SetEventLog(writer io.Writer)
 	ListenForEvents() error
 	StopEventListening() error
@@ -99,22 +182,52 @@ type Client interface {
 	Get(url string) (string, error)
 }
 
+// Option configures optional behavior of a Client created by Connect.
+type Option func(c *client)
+
+// WithLogger sets the logger for the event loop, rediscovery and requests to the hub. By default, the client
+// does not log.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *client) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// ConnectionHandler is called when the WebSocket connection used for events is established or closed. The error
+// contains the reason for closing the connection and is nil when the connection is established.
+type ConnectionHandler func(connected bool, err error)
+
+// WithConnectionHandler registers a handler for changes of the WebSocket connection used by ListenForEvents,
+// e.g. to monitor the health of the event loop.
+func WithConnectionHandler(handler ConnectionHandler) Option {
+	return func(c *client) {
+		c.connectionHandlers = append(c.connectionHandlers, handler)
+	}
+}
+
 type client struct {
 	httpClient          *http.Client
 	authorization       *Authorization
 	endpoint            string
+	endpointMutex       sync.RWMutex
+	rediscovery         *rediscovery
+	connectionHandlers  []ConnectionHandler
+	telemetry           *telemetry
 	registrations       []handlerRegistration
 	eventLoopMutex      sync.Mutex
 	eventLoopContext    context.Context
 	eventLoopCancelFunc context.CancelFunc
 	eventLoopError      error
-	eventLog            io.Writer
+	logger              *slog.Logger
 	websocketDialer     *websocket.Dialer
 	websocketConnection *websocket.Conn
 }
 
 // Connect creates a new Client and provides functions to communicate with the IKEA Smart-Home hub.
-func Connect(address string, port int, authorization *Authorization) Client {
+// The behavior of the client can be adjusted by options like WithRediscovery.
+func Connect(address string, port int, authorization *Authorization, options ...Option) Client {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify:    true,
 		VerifyPeerCertificate: fingerprintVerifier(authorization, false),
@@ -125,9 +238,9 @@ func Connect(address string, port int, authorization *Authorization) Client {
 			TLSClientConfig: tlsConfig,
 		},
 	}
-	return &client{
+	c := &client{
 		authorization: authorization,
-		endpoint:      fmt.Sprintf("%s:%d/v1", address, port),
+		endpoint:      formatEndpoint(address, port),
 		httpClient: &http.Client{
 			Transport: transport,
 		},
@@ -136,12 +249,64 @@ func Connect(address string, port int, authorization *Authorization) Client {
 		},
 		eventLoopContext:    nil,
 		eventLoopCancelFunc: nil,
-		eventLog:            os.Stdout,
+		logger:              slog.New(slog.DiscardHandler),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	transport.origin = &loggingRoundTripper{
+		logger: c.logger,
+		origin: transport.origin,
+	}
+	if c.rediscovery != nil {
+		c.httpClient.Transport = &rediscoveryRoundTripper{
+			client: c,
+			origin: transport,
+		}
+	}
+	if c.telemetry != nil {
+		// Outermost, so a retry after rediscovery is part of the same span
+		c.httpClient.Transport = &telemetryRoundTripper{
+			telemetry: c.telemetry,
+			origin:    c.httpClient.Transport,
+		}
 	}
+
+	return c
+}
+
+type loggingRoundTripper struct {
+	logger *slog.Logger
+	origin http.RoundTripper
+}
+
+func (rt *loggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := rt.origin.RoundTrip(request)
+	if err != nil {
+		rt.logger.Debug("request failed", "method", request.Method, "address", request.URL.Host, "path", request.URL.Path,
+			"duration", time.Since(start), "error", err)
+		return nil, err
+	}
+	rt.logger.Debug("request completed", "method", request.Method, "address", request.URL.Host, "path", request.URL.Path,
+		"duration", time.Since(start), "status", response.StatusCode)
+
+	return response, nil
+}
+
+func formatEndpoint(address string, port int) string {
+	return fmt.Sprintf("%s/v1", net.JoinHostPort(address, strconv.Itoa(port)))
+}
+
+func (c *client) getEndpoint() string {
+	c.endpointMutex.RLock()
+	defer c.endpointMutex.RUnlock()
+
+	return c.endpoint
 }
 
 func (c *client) ListDevices() ([]*Device, error) {
-	targetURL := fmt.Sprintf("https://%s/devices", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/devices", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error listing devices from %s: %w", targetURL, err)
@@ -161,7 +326,7 @@ func (c *client) ListDevices() ([]*Device, error) {
 }
 
 func (c *client) GetHubStatus() (*Device, error) {
-	targetURL := fmt.Sprintf("https://%s/hub/status", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/hub/status", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading hub status from %s: %w", targetURL, err)
@@ -180,8 +345,71 @@ func (c *client) GetHubStatus() (*Device, error) {
 	return device, nil
 }
 
+func (c *client) GetFirmwareUpdateStatus() (*FirmwareUpdateStatus, error) {
+	targetURL := fmt.Sprintf("https://%s/hub/status", c.getEndpoint())
+	response, err := c.httpClient.Get(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("error reading firmware update status from %s: %w", targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error reading firmware update status from %s: Received status code %d", targetURL, response.StatusCode)
+	}
+
+	var hub struct {
+		Attributes *FirmwareUpdateStatus `json:"attributes"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&hub); err != nil {
+		return nil, fmt.Errorf("error decoding firmware update status response: %w", err)
+	}
+	if hub.Attributes == nil {
+		return nil, fmt.Errorf("error reading firmware update status from %s: No attributes received", targetURL)
+	}
+
+	return hub.Attributes, nil
+}
+
+func (c *client) CheckFirmwareUpdate() error {
+	targetURL := fmt.Sprintf("https://%s/hub/ota/check", c.getEndpoint())
+	request, err := http.NewRequest("PUT", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating firmware update check call: %w", err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error checking for firmware update at %s: %w", targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error checking for firmware update at %s: Received status code %d", targetURL, response.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *client) InstallFirmwareUpdate() error {
+	targetURL := fmt.Sprintf("https://%s/hub/ota/update", c.getEndpoint())
+	request, err := http.NewRequest("PUT", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating firmware update call: %w", err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error installing firmware update at %s: %w", targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error installing firmware update at %s: Received status code %d", targetURL, response.StatusCode)
+	}
+
+	return nil
+}
+
 func (c *client) GetDevice(deviceID string) (*Device, error) {
-	targetURL := fmt.Sprintf("https://%s/devices/%s", c.endpoint, deviceID)
+	targetURL := fmt.Sprintf("https://%s/devices/%s", c.getEndpoint(), deviceID)
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading device %s from %s: %w", deviceID, targetURL, err)
@@ -200,8 +428,38 @@ func (c *client) GetDevice(deviceID string) (*Device, error) {
 	return device, nil
 }
 
+func (c *client) SetDeviceAttributes(deviceID string, attributes map[string]interface{}, transitionTime time.Duration) error {
+	targetURL := fmt.Sprintf("https://%s/devices/%s", c.getEndpoint(), deviceID)
+	update := map[string]interface{}{
+		"attributes": attributes,
+	}
+	if transitionTime > 0 {
+		update["transitionTime"] = transitionTime.Milliseconds()
+	}
+	body, err := json.Marshal([]interface{}{update})
+	if err != nil {
+		return fmt.Errorf("error encoding attributes for device %s: %w", deviceID, err)
+	}
+	request, err := http.NewRequest("PATCH", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating patch call for device %s: %w", deviceID, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error updating device %s at %s: %w", deviceID, targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error updating device %s at %s: Received status code %d", deviceID, targetURL, response.StatusCode)
+	}
+
+	return nil
+}
+
 func (c *client) ListRooms() ([]*Room, error) {
-	targetURL := fmt.Sprintf("https://%s/rooms", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/rooms", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error listing rooms from %s: %w", targetURL, err)
@@ -221,7 +479,7 @@ func (c *client) ListRooms() ([]*Room, error) {
 }
 
 func (c *client) GetRoom(roomID string) (*Room, error) {
-	targetURL := fmt.Sprintf("https://%s/rooms/%s", c.endpoint, roomID)
+	targetURL := fmt.Sprintf("https://%s/rooms/%s", c.getEndpoint(), roomID)
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading room %s from %s: %w", roomID, targetURL, err)
@@ -241,7 +499,7 @@ func (c *client) GetRoom(roomID string) (*Room, error) {
 }
 
 func (c *client) ListScenes() ([]*Scene, error) {
-	targetURL := fmt.Sprintf("https://%s/scenes", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/scenes", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error listing scenes from %s: %w", targetURL, err)
@@ -261,7 +519,7 @@ func (c *client) ListScenes() ([]*Scene, error) {
 }
 
 func (c *client) GetScene(sceneID string) (*Scene, error) {
-	targetURL := fmt.Sprintf("https://%s/scenes/%s", c.endpoint, sceneID)
+	targetURL := fmt.Sprintf("https://%s/scenes/%s", c.getEndpoint(), sceneID)
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading scene %s from %s: %w", sceneID, targetURL, err)
@@ -280,8 +538,27 @@ func (c *client) GetScene(sceneID string) (*Scene, error) {
 	return scene, nil
 }
 
+func (c *client) TriggerScene(sceneID string) error {
+	targetURL := fmt.Sprintf("https://%s/scenes/%s/trigger", c.getEndpoint(), sceneID)
+	request, err := http.NewRequest("POST", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating trigger call for scene %s: %w", sceneID, err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error triggering scene %s at %s: %w", sceneID, targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error triggering scene %s at %s: Received status code %d", sceneID, targetURL, response.StatusCode)
+	}
+
+	return nil
+}
+
 func (c *client) ListUsers() ([]*User, error) {
-	targetURL := fmt.Sprintf("https://%s/users", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/users", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error listing users from %s: %w", targetURL, err)
@@ -301,7 +578,7 @@ func (c *client) ListUsers() ([]*User, error) {
 }
 
 func (c *client) GetUser(userID string) (*User, error) {
-	targetURL := fmt.Sprintf("https://%s/users/%s", c.endpoint, userID)
+	targetURL := fmt.Sprintf("https://%s/users/%s", c.getEndpoint(), userID)
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading user %s from %s: %w", userID, targetURL, err)
@@ -321,7 +598,7 @@ func (c *client) GetUser(userID string) (*User, error) {
 }
 
 func (c *client) GetCurrentUser() (*User, error) {
-	targetURL := fmt.Sprintf("https://%s/users/me", c.endpoint)
+	targetURL := fmt.Sprintf("https://%s/users/me", c.getEndpoint())
 	response, err := c.httpClient.Get(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("error reading current user from %s: %w", targetURL, err)
@@ -340,8 +617,34 @@ func (c *client) GetCurrentUser() (*User, error) {
 	return user, nil
 }
 
+func (c *client) UpdateCurrentUser(name string) error {
+	targetURL := fmt.Sprintf("https://%s/users/me", c.getEndpoint())
+	body, err := json.Marshal(map[string]string{
+		"name": name,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding current user: %w", err)
+	}
+	request, err := http.NewRequest("PATCH", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating patch call for current user: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error updating current user at %s: %w", targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error updating current user at %s: Received status code %d", targetURL, response.StatusCode)
+	}
+
+	return nil
+}
+
 func (c *client) DeleteUser(userID string) error {
-	targetURL := fmt.Sprintf("https://%s/users/%s", c.endpoint, userID)
+	targetURL := fmt.Sprintf("https://%s/users/%s", c.getEndpoint(), userID)
 	request, err := http.NewRequest("DELETE", targetURL, nil)
 	if err != nil {
 		return fmt.Errorf("error creating delete call for user %s: %w", userID, err)
@@ -366,8 +669,14 @@ func (c *client) RegisterEventHandler(handler EventHandler, eventTypes ...string
 	})
 }
 
+// RegisterConnectionHandler adds a handler for changes of the WebSocket connection like WithConnectionHandler.
+// Handlers are called by the event loop before further events are passed to the event handlers.
+func (c *client) RegisterConnectionHandler(handler ConnectionHandler) {
+	c.connectionHandlers = append(c.connectionHandlers, handler)
+}
+
 func (c *client) SetEventLog(writer io.Writer) {
-	c.eventLog = writer
+	c.logger = slog.New(slog.NewTextHandler(writer, nil))
 }
 
 func (c *client) ListenForEvents() error {
@@ -380,8 +689,10 @@ func (c *client) ListenForEvents() error {
 	c.eventLoopError = nil
 	c.eventLoopMutex.Unlock()
 
+	attempt := 0
 	for {
 		if err := c.eventLoop(); err != nil {
+			attempt++
 			c.eventLoopMutex.Lock()
 			c.eventLoopError = err
 			c.eventLoopMutex.Unlock()
@@ -390,10 +701,10 @@ func (c *client) ListenForEvents() error {
 			select {
 			case <-c.eventLoopContext.Done():
 			default:
-				_, _ = fmt.Fprintf(c.eventLog, "Error in event loop: %v\nRestarting event loop in 30 seconds\n", err)
+				c.logger.Warn("event loop failed, restarting", "address", c.getEndpoint(), "error", err, "attempt", attempt, "retry_in", eventLoopRetryInterval)
 			}
 
-			timer := time.NewTimer(30 * time.Second)
+			timer := time.NewTimer(eventLoopRetryInterval)
 			select {
 			case <-timer.C:
 
@@ -419,39 +730,65 @@ func (c *client) ListenForEvents() error {
 	}
 }
 
-func (c *client) eventLoop() error {
-	var err error
-
-	websocketURL := fmt.Sprintf("wss://%s", c.endpoint)
+func (c *client) eventLoop() (err error) {
 	websocketHeader := http.Header{}
 	websocketHeader.Set("Authorization", "Bearer "+c.authorization.AccessToken)
 
+	connectStart := time.Now()
 	c.eventLoopMutex.Lock()
-	c.websocketConnection, _, err = c.websocketDialer.Dial(websocketURL, websocketHeader)
+	ctx := c.eventLoopContext
 	c.eventLoopMutex.Unlock()
+	// The mutex is not held while connecting, so StopEventListening is not blocked by a scan for the hub
+	connection, _, err := c.websocketDialer.DialContext(ctx, fmt.Sprintf("wss://%s", c.getEndpoint()), websocketHeader)
+	if err != nil && ctx.Err() == nil {
+		if previousEndpoint, switched := c.rediscoverEndpoint(ctx); switched {
+			connection, _, err = c.websocketDialer.DialContext(ctx, fmt.Sprintf("wss://%s", c.getEndpoint()), websocketHeader)
+			c.completeRediscovery(previousEndpoint, err == nil)
+		}
+	}
+	if err == nil {
+		c.eventLoopMutex.Lock()
+		if err = ctx.Err(); err == nil {
+			c.websocketConnection = connection
+		} else {
+			// Stopped while connecting
+			_ = connection.Close()
+		}
+		c.eventLoopMutex.Unlock()
+	}
+	c.telemetry.websocketConnected(c.getEndpoint(), connectStart, err)
 	if err != nil {
 		return err
 	}
 	defer func(conn *websocket.Conn) {
-		_, _ = fmt.Fprintf(c.eventLog, "\U0001F6AB Closing connection to %s\n", conn.RemoteAddr().String())
+		c.logger.Info("closing event connection", "address", conn.RemoteAddr().String())
 		_ = conn.Close()
 
 		c.eventLoopMutex.Lock()
 		c.websocketConnection = nil
 		c.eventLoopMutex.Unlock()
-	}(c.websocketConnection)
-	_, _ = fmt.Fprintf(c.eventLog, "\U0001F50C Established connection to %v\n", c.websocketConnection.RemoteAddr())
+		c.telemetry.websocketDisconnected(c.getEndpoint(), err)
+		for _, handler := range c.connectionHandlers {
+			handler(false, err)
+		}
+	}(connection)
+	c.logger.Info("established event connection", "address", connection.RemoteAddr().String())
+	for _, handler := range c.connectionHandlers {
+		handler(true, nil)
+	}
 	for {
 		event := &Event{}
-		err := c.websocketConnection.ReadJSON(event)
-		if err != nil {
+		if err := connection.ReadJSON(event); err != nil {
 			return err
 		}
-		for _, registration := range c.registrations {
-			if len(registration.Types) == 0 || slices.Contains(registration.Types, event.Type) {
-				registration.Handler(*event)
+		c.logger.Debug("received event", "event_type", event.Type, "event_id", event.ID, "device_id", event.Device.ID)
+		c.telemetry.dispatchEvent(event, func() {
+			for _, registration := range c.registrations {
+				if len(registration.Types) == 0 || slices.Contains(registration.Types, event.Type) {
+					registration.Handler(*event)
+				}
 			}
-		}
+		})
 	}
 }
 
@@ -479,9 +816,9 @@ func (c *client) StopEventListening() error {
 }
 
 func (c *client) Get(path string) (string, error) {
-	base, err := url.Parse(fmt.Sprintf("https://%s", c.endpoint))
+	base, err := url.Parse(fmt.Sprintf("https://%s", c.getEndpoint()))
 	if err != nil {
-		return "", fmt.Errorf("error parsing address %q: %w", c.endpoint, err)
+		return "", fmt.Errorf("error parsing address %q: %w", c.getEndpoint(), err)
 	}
 	targetURL := base.JoinPath(path).String()
 	response, err := c.httpClient.Get(targetURL)
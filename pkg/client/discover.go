@@ -1,54 +1,262 @@
 package client
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/mdns"
 )
 
+const (
+	defaultScanTimeout    = time.Second
+	defaultBrowseInterval = 30 * time.Second
+	defaultMissedScans    = 3
+)
+
 type DirigeraHub struct {
-	HostName        string `json:"hostname"`
-	Address         string `json:"address"`
-	Port            int    `json:"port"`
-	SerialNumber    string `json:"serial_number"`
-	FirmwareVersion string `json:"firmware_version"`
+	HostName        string            `json:"hostname"`
+	Address         string            `json:"address"`
+	Addresses       []string          `json:"addresses"`
+	Port            int               `json:"port"`
+	SerialNumber    string            `json:"serial_number"`
+	FirmwareVersion string            `json:"firmware_version"`
+	Info            map[string]string `json:"info"`
+}
+
+// ScanOptions controls how ScanWithOptions and Browse search for hubs. The zero value scans on all interfaces
+// using IPv4 and IPv6 with the default timeout of one second.
+type ScanOptions struct {
+	Timeout     time.Duration
+	Interface   *net.Interface
+	DisableIPv4 bool
+	DisableIPv6 bool
+	// Logger receives the messages of the mDNS library at debug level, they are discarded if not set.
+	Logger *slog.Logger
+}
+
+// BrowseOptions controls the continuous search of Browse. A hub is reported as disappeared when it was not found
+// in MissedScans consecutive scans, which are started every Interval.
+type BrowseOptions struct {
+	ScanOptions
+	Interval    time.Duration
+	MissedScans int
+}
+
+type HubEventType string
+
+const (
+	HubAppeared    HubEventType = "appeared"
+	HubChanged     HubEventType = "changed"
+	HubDisappeared HubEventType = "disappeared"
+)
+
+type HubEvent struct {
+	Type HubEventType `json:"type"`
+	Hub  DirigeraHub  `json:"hub"`
 }
 
 // Scan searches for IKEA Smart-Home hubs in the network using mDNS.
 func Scan() ([]DirigeraHub, error) {
-	var hubs []DirigeraHub
+	return ScanWithOptions(context.Background(), ScanOptions{
+		DisableIPv6: true,
+	})
+}
 
-	// Disable log output temporary
-	oldLog := log.Default()
-	log.SetOutput(io.Discard)
-	defer log.SetOutput(oldLog.Writer())
+// ScanWithOptions searches for IKEA Smart-Home hubs in the network using mDNS with the specified options.
+// The scan ends after the timeout or when the context is cancelled. Answers for the same hub are merged,
+// so every hub is returned once with all of its addresses.
+func ScanWithOptions(ctx context.Context, options ScanOptions) ([]DirigeraHub, error) {
+	if options.DisableIPv4 && options.DisableIPv6 {
+		return nil, fmt.Errorf("at least one of IPv4 and IPv6 must be enabled")
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultScanTimeout
+	}
 
-	// Scan for hubs
+	hubs := make(map[string]*DirigeraHub)
+	var order []string
+	var collecting sync.WaitGroup
 	entriesChannel := make(chan *mdns.ServiceEntry, 4)
+	collecting.Add(1)
 	go func() {
+		defer collecting.Done()
 		for entry := range entriesChannel {
 			info := convertToMap(entry.InfoFields)
-			if info["type"] == "DIRIGERA" {
-				hubs = append(hubs, DirigeraHub{
+			if info["type"] != "DIRIGERA" {
+				continue
+			}
+			key := info["uuid"]
+			if key == "" {
+				key = entry.Name
+			}
+			hub, found := hubs[key]
+			if !found {
+				hub = &DirigeraHub{
 					HostName:        info["hostname"],
-					Address:         entry.AddrV4.String(),
 					Port:            entry.Port,
 					FirmwareVersion: info["sv"],
 					SerialNumber:    info["uuid"],
-				})
-
+					Info:            info,
+				}
+				hubs[key] = hub
+				order = append(order, key)
+			}
+			for _, address := range entryAddresses(entry) {
+				if !slices.Contains(hub.Addresses, address) {
+					hub.Addresses = append(hub.Addresses, address)
+				}
 			}
+			hub.Address = primaryAddress(hub.Addresses)
 		}
 	}()
+
 	params := mdns.DefaultParams("_ihsp._tcp")
 	params.Entries = entriesChannel
-	params.DisableIPv6 = true
-	err := mdns.Query(params)
+	params.Timeout = options.Timeout
+	params.Interface = options.Interface
+	params.DisableIPv4 = options.DisableIPv4
+	params.DisableIPv6 = options.DisableIPv6
+	params.Logger = log.New(io.Discard, "", 0)
+	if options.Logger != nil {
+		params.Logger = slog.NewLogLogger(options.Logger.Handler(), slog.LevelDebug)
+	}
+	err := mdns.QueryContext(ctx, params)
 	close(entriesChannel)
+	collecting.Wait()
+
+	result := make([]DirigeraHub, 0, len(order))
+	for _, key := range order {
+		hub := hubs[key]
+		slices.Sort(hub.Addresses)
+		result = append(result, *hub)
+	}
+	if err != nil && ctx.Err() != nil {
+		// A cancelled scan returns the hubs found so far
+		err = nil
+	}
+
+	return result, err
+}
+
+// Browse searches for hubs continuously and reports hubs that appeared, changed their address or firmware version,
+// or disappeared. The returned channel is closed when the context is cancelled.
+func Browse(ctx context.Context, options BrowseOptions) <-chan HubEvent {
+	if options.Interval <= 0 {
+		options.Interval = defaultBrowseInterval
+	}
+	if options.MissedScans <= 0 {
+		options.MissedScans = defaultMissedScans
+	}
+
+	events := make(chan HubEvent)
+	go func() {
+		defer close(events)
+
+		knownHubs := make(map[string]DirigeraHub)
+		missedScans := make(map[string]int)
+		ticker := time.NewTicker(options.Interval)
+		defer ticker.Stop()
+		send := func(event HubEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			hubs, err := ScanWithOptions(ctx, options.ScanOptions)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				seen := make(map[string]bool, len(hubs))
+				for _, hub := range hubs {
+					seen[hub.SerialNumber] = true
+					missedScans[hub.SerialNumber] = 0
+					knownHub, known := knownHubs[hub.SerialNumber]
+					knownHubs[hub.SerialNumber] = hub
+					switch {
+					case !known:
+						if !send(HubEvent{Type: HubAppeared, Hub: hub}) {
+							return
+						}
+					case hubChanged(knownHub, hub):
+						if !send(HubEvent{Type: HubChanged, Hub: hub}) {
+							return
+						}
+					}
+				}
+				for serialNumber, knownHub := range knownHubs {
+					if seen[serialNumber] {
+						continue
+					}
+					missedScans[serialNumber]++
+					if missedScans[serialNumber] >= options.MissedScans {
+						delete(knownHubs, serialNumber)
+						delete(missedScans, serialNumber)
+						if !send(HubEvent{Type: HubDisappeared, Hub: knownHub}) {
+							return
+						}
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
+
+func hubChanged(previous, current DirigeraHub) bool {
+	return previous.Address != current.Address ||
+		previous.Port != current.Port ||
+		previous.FirmwareVersion != current.FirmwareVersion ||
+		!slices.Equal(previous.Addresses, current.Addresses) ||
+		!maps.Equal(previous.Info, current.Info)
+}
+
+func entryAddresses(entry *mdns.ServiceEntry) []string {
+	var addresses []string
+	if entry.AddrV4 != nil {
+		addresses = append(addresses, entry.AddrV4.String())
+	}
+	if entry.AddrV6IPAddr != nil {
+		addresses = append(addresses, entry.AddrV6IPAddr.String())
+	} else if entry.AddrV6 != nil {
+		addresses = append(addresses, entry.AddrV6.String())
+	}
+
+	return addresses
+}
+
+// primaryAddress prefers IPv4 addresses, because link-local IPv6 addresses need a zone to be usable.
+func primaryAddress(addresses []string) string {
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil && ip.To4() != nil {
+			return address
+		}
+	}
+	if len(addresses) > 0 {
+		return addresses[0]
+	}
 
-	return hubs, err
+	return ""
 }
 
 func convertToMap(array []string) map[string]string {
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	EnvAddress     = "IKEA_ADDRESS"
+	EnvPort        = "IKEA_PORT"
+	EnvToken       = "IKEA_TOKEN"
+	EnvFingerprint = "IKEA_FINGERPRINT"
+	EnvContext     = "IKEA_CONTEXT"
+
+	defaultPort = 8443
+)
+
+// EnvConfig contains the connection settings read from the environment variables IKEA_ADDRESS, IKEA_PORT,
+// IKEA_TOKEN and IKEA_FINGERPRINT. IKEA_FINGERPRINT may contain additional comma separated fingerprints
+// that are pinned during the rotation of the hub certificate.
+type EnvConfig struct {
+	Address     string
+	Port        int
+	AccessToken string
+	Fingerprint string
+	// PinnedFingerprints contains the additional fingerprints of a comma separated IKEA_FINGERPRINT.
+	PinnedFingerprints []string
+}
+
+// EnvConfigured returns true if at least one of the connection settings is defined by an environment variable.
+func EnvConfigured() bool {
+	for _, name := range []string{EnvAddress, EnvPort, EnvToken, EnvFingerprint} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadEnvConfig reads and validates the connection settings from the environment. The port defaults to 8443,
+// all other variables are required. The returned error lists every missing or invalid variable.
+func LoadEnvConfig() (*EnvConfig, error) {
+	config := &EnvConfig{
+		Address:     strings.TrimSpace(os.Getenv(EnvAddress)),
+		Port:        defaultPort,
+		AccessToken: strings.TrimSpace(os.Getenv(EnvToken)),
+	}
+	for index, fingerprint := range strings.Split(os.Getenv(EnvFingerprint), ",") {
+		fingerprint = normalizeFingerprint(strings.TrimSpace(fingerprint))
+		if index == 0 {
+			config.Fingerprint = fingerprint
+		} else if fingerprint != "" {
+			config.PinnedFingerprints = append(config.PinnedFingerprints, fingerprint)
+		}
+	}
+
+	var errs []error
+	if config.Address == "" {
+		errs = append(errs, fmt.Errorf("%s is not set", EnvAddress))
+	} else if strings.ContainsAny(config.Address, "/: ") {
+		if _, err := netip.ParseAddr(config.Address); err != nil {
+			errs = append(errs, fmt.Errorf("%s must be an IP address or hostname without scheme or port: %q", EnvAddress, config.Address))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(EnvPort)); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be a number between 1 and 65535: %q", EnvPort, value))
+		}
+		config.Port = port
+	}
+	if config.AccessToken == "" {
+		errs = append(errs, fmt.Errorf("%s is not set", EnvToken))
+	}
+	if config.Fingerprint == "" {
+		errs = append(errs, fmt.Errorf("%s is not set", EnvFingerprint))
+	} else if !isSHA256Fingerprint(config.Fingerprint) || slices.ContainsFunc(config.PinnedFingerprints, func(fingerprint string) bool {
+		return !isSHA256Fingerprint(fingerprint)
+	}) {
+		errs = append(errs, fmt.Errorf("%s must contain comma separated SHA-256 fingerprints with 64 hex digits: %q", EnvFingerprint, os.Getenv(EnvFingerprint)))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid environment configuration: %w", errors.Join(errs...))
+	}
+
+	return config, nil
+}
+
+// FromEnv creates a new Client with the connection settings read from the environment, see LoadEnvConfig.
+func FromEnv(options ...Option) (Client, error) {
+	config, err := LoadEnvConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return Connect(config.Address, config.Port, &Authorization{
+		AccessToken:        config.AccessToken,
+		TLSFingerprint:     config.Fingerprint,
+		PinnedFingerprints: config.PinnedFingerprints,
+	}, options...), nil
+}
+
+func isSHA256Fingerprint(fingerprint string) bool {
+	if len(fingerprint) != 64 {
+		return false
+	}
+	for _, character := range fingerprint {
+		if !strings.ContainsRune("0123456789abcdef", character) {
+			return false
+		}
+	}
+
+	return true
+}
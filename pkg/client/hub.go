@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Hub struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"`
+	Model           string       `json:"model"`
+	SerialNumber    string       `json:"serialNumber"`
+	FirmwareVersion string       `json:"firmwareVersion"`
+	HardwareVersion string       `json:"hardwareVersion"`
+	CountryCode     string       `json:"countryCode"`
+	Timezone        string       `json:"timezone"`
+	Coordinates     *Coordinates `json:"coordinates,omitempty"`
+	StatusLightOn   bool         `json:"statusLightOn"`
+	IsReachable     bool         `json:"isReachable"`
+	CreatedAt       time.Time    `json:"createdAt"`
+	LastSeen        time.Time    `json:"lastSeen"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy"`
+}
+
+// NewHub converts the hub status returned by GetHubStatus into a Hub.
+func NewHub(status *Device) *Hub {
+	hub := &Hub{
+		ID:          status.ID,
+		IsReachable: status.IsReachable,
+		CreatedAt:   status.CreatedAt,
+		LastSeen:    status.LastSeen,
+	}
+	hub.Name, _ = status.Attributes["customName"].(string)
+	hub.Model, _ = status.Attributes["model"].(string)
+	hub.SerialNumber, _ = status.Attributes["serialNumber"].(string)
+	hub.FirmwareVersion, _ = status.Attributes["firmwareVersion"].(string)
+	hub.HardwareVersion, _ = status.Attributes["hardwareVersion"].(string)
+	hub.CountryCode, _ = status.Attributes["countryCode"].(string)
+	hub.Timezone, _ = status.Attributes["timezone"].(string)
+	hub.StatusLightOn, _ = status.Attributes["isOn"].(bool)
+	if coordinates, isMap := status.Attributes["coordinates"].(map[string]interface{}); isMap {
+		hub.Coordinates = &Coordinates{}
+		hub.Coordinates.Latitude, _ = coordinates["latitude"].(float64)
+		hub.Coordinates.Longitude, _ = coordinates["longitude"].(float64)
+		hub.Coordinates.Accuracy, _ = coordinates["accuracy"].(float64)
+	}
+
+	return hub
+}
+
+func (c *client) GetHub() (*Hub, error) {
+	status, err := c.GetHubStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHub(status), nil
+}
+
+func (c *client) SetHubName(name string) error {
+	return c.setHubAttributes(map[string]interface{}{
+		"customName": name,
+	})
+}
+
+func (c *client) SetHubTimezone(timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone %s: %w", timezone, err)
+	}
+
+	return c.setHubAttributes(map[string]interface{}{
+		"timezone": timezone,
+	})
+}
+
+func (c *client) SetHubCoordinates(coordinates Coordinates) error {
+	if coordinates.Latitude < -90 || coordinates.Latitude > 90 {
+		return fmt.Errorf("invalid latitude %f: must be between -90 and 90", coordinates.Latitude)
+	}
+	if coordinates.Longitude < -180 || coordinates.Longitude > 180 {
+		return fmt.Errorf("invalid longitude %f: must be between -180 and 180", coordinates.Longitude)
+	}
+
+	return c.setHubAttributes(map[string]interface{}{
+		"coordinates": coordinates,
+	})
+}
+
+func (c *client) SetHubStatusLight(on bool) error {
+	return c.setHubAttributes(map[string]interface{}{
+		"isOn": on,
+	})
+}
+
+func (c *client) setHubAttributes(attributes map[string]interface{}) error {
+	targetURL := fmt.Sprintf("https://%s/hub", c.getEndpoint())
+	body, err := json.Marshal([]interface{}{map[string]interface{}{
+		"attributes": attributes,
+	}})
+	if err != nil {
+		return fmt.Errorf("error encoding hub attributes: %w", err)
+	}
+	request, err := http.NewRequest("PATCH", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating patch call for hub: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error updating hub at %s: %w", targetURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("error updating hub at %s: Received status code %d", targetURL, response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rediscoveryInterval = 30 * time.Second
+
+type rediscovery struct {
+	serialNumber    string
+	onAddressChange func(address string, port int)
+	mutex           sync.Mutex
+	lastScan        time.Time
+}
+
+// WithRediscovery enables the automatic rediscovery of the hub with the specified serial number (as reported by Scan)
+// when it is not reachable at the configured address anymore, e.g. after the hub got a new address by DHCP.
+// The request is retried at the new address, which is only kept if the TLS fingerprint of the hub matches.
+// The function onAddressChange is called with the new address and port after a successful retry and may be nil.
+func WithRediscovery(serialNumber string, onAddressChange func(address string, port int)) Option {
+	return func(c *client) {
+		if serialNumber == "" {
+			return
+		}
+		c.rediscovery = &rediscovery{
+			serialNumber:    serialNumber,
+			onAddressChange: onAddressChange,
+		}
+	}
+}
+
+type rediscoveryRoundTripper struct {
+	client *client
+	origin http.RoundTripper
+}
+
+func (rt *rediscoveryRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := rt.origin.RoundTrip(request)
+	if err == nil || request.Context().Err() != nil {
+		return response, err
+	}
+	if request.Body != nil && request.GetBody == nil {
+		return response, err
+	}
+
+	previousEndpoint, switched := rt.client.rediscoverEndpoint(request.Context())
+	if !switched {
+		return response, err
+	}
+	retry := request.Clone(request.Context())
+	retry.URL.Host = strings.SplitN(rt.client.getEndpoint(), "/", 2)[0]
+	retry.Host = ""
+	if request.GetBody != nil {
+		retry.Body, err = request.GetBody()
+		if err != nil {
+			rt.client.completeRediscovery(previousEndpoint, false)
+			return nil, err
+		}
+	}
+	response, err = rt.origin.RoundTrip(retry)
+	rt.client.completeRediscovery(previousEndpoint, err == nil)
+
+	return response, err
+}
+
+// rediscoverEndpoint scans for the hub and switches the endpoint if the hub was found at a different address.
+// It returns the previous endpoint and whether the endpoint was switched. Scans are limited to one per interval and
+// end early when the context is cancelled.
+func (c *client) rediscoverEndpoint(ctx context.Context) (string, bool) {
+	if c.rediscovery == nil {
+		return "", false
+	}
+	c.rediscovery.mutex.Lock()
+	defer c.rediscovery.mutex.Unlock()
+
+	previousEndpoint := c.getEndpoint()
+	if time.Since(c.rediscovery.lastScan) < rediscoveryInterval {
+		return previousEndpoint, false
+	}
+	c.rediscovery.lastScan = time.Now()
+
+	c.logger.Info("hub not reachable, searching for its address", "serial_number", c.rediscovery.serialNumber, "address", previousEndpoint)
+	hubs, err := ScanWithOptions(ctx, ScanOptions{
+		DisableIPv6: true,
+		Logger:      c.logger,
+	})
+	if err != nil {
+		c.logger.Warn("could not search for hub", "serial_number", c.rediscovery.serialNumber, "error", err)
+		return previousEndpoint, false
+	}
+	for _, hub := range hubs {
+		if hub.SerialNumber != c.rediscovery.serialNumber {
+			continue
+		}
+		endpoint := formatEndpoint(hub.Address, hub.Port)
+		if endpoint == previousEndpoint {
+			return previousEndpoint, false
+		}
+		c.endpointMutex.Lock()
+		c.endpoint = endpoint
+		c.endpointMutex.Unlock()
+		c.logger.Info("found hub at new address", "serial_number", c.rediscovery.serialNumber, "address", endpoint, "previous_address", previousEndpoint)
+
+		return previousEndpoint, true
+	}
+
+	return previousEndpoint, false
+}
+
+// completeRediscovery keeps the new endpoint and notifies about the address change if the retry was successful
+// and restores the previous endpoint otherwise.
+func (c *client) completeRediscovery(previousEndpoint string, successful bool) {
+	if !successful {
+		c.logger.Warn("hub not accepted at new address, keeping previous address", "serial_number", c.rediscovery.serialNumber, "address", c.getEndpoint(), "previous_address", previousEndpoint)
+		c.endpointMutex.Lock()
+		c.endpoint = previousEndpoint
+		c.endpointMutex.Unlock()
+		return
+	}
+	if c.rediscovery.onAddressChange == nil {
+		return
+	}
+	host, portValue, err := net.SplitHostPort(strings.SplitN(c.getEndpoint(), "/", 2)[0])
+	if err != nil {
+		return
+	}
+	port, _ := strconv.Atoi(portValue)
+	c.rediscovery.onAddressChange(host, port)
+}
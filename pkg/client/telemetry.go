@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/salex-org/ikea-dirigera-client/pkg/client"
+
+// Collections of the API whose next path segment is an ID, used to build path templates with low cardinality.
+var idCollections = []string{"devices", "rooms", "scenes", "users"}
+
+type telemetry struct {
+	tracer          trace.Tracer
+	requestDuration metric.Float64Histogram
+	eventsReceived  metric.Int64Counter
+	connections     metric.Int64UpDownCounter
+}
+
+// WithTelemetry enables OpenTelemetry instrumentation of the client: spans and a latency histogram for every REST
+// call, spans for connecting and disconnecting the WebSocket, a span for every dispatched event and a counter of
+// received events. Without this option the client does not create any spans or metrics.
+func WithTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) Option {
+	return func(c *client) {
+		t := &telemetry{}
+		if tracerProvider != nil {
+			t.tracer = tracerProvider.Tracer(instrumentationName)
+		}
+		if meterProvider != nil {
+			meter := meterProvider.Meter(instrumentationName)
+			// Instruments that can not be created are left nil and skipped
+			t.requestDuration, _ = meter.Float64Histogram("dirigera.client.request.duration",
+				metric.WithDescription("Duration of REST calls to the hub"),
+				metric.WithUnit("s"))
+			t.eventsReceived, _ = meter.Int64Counter("dirigera.client.events.received",
+				metric.WithDescription("Number of events received from the hub"),
+				metric.WithUnit("{event}"))
+			t.connections, _ = meter.Int64UpDownCounter("dirigera.client.websocket.connections",
+				metric.WithDescription("Number of established WebSocket connections to the hub"),
+				metric.WithUnit("{connection}"))
+		}
+		c.telemetry = t
+	}
+}
+
+type telemetryRoundTripper struct {
+	telemetry *telemetry
+	origin    http.RoundTripper
+}
+
+func (rt *telemetryRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	pathTemplate := templatePath(request.URL.Path)
+	attributes := []attribute.KeyValue{
+		attribute.String("http.request.method", request.Method),
+		attribute.String("url.template", pathTemplate),
+		attribute.String("server.address", request.URL.Hostname()),
+	}
+	if port, err := strconv.Atoi(request.URL.Port()); err == nil {
+		attributes = append(attributes, attribute.Int("server.port", port))
+	}
+
+	ctx := request.Context()
+	var span trace.Span
+	if rt.telemetry.tracer != nil {
+		ctx, span = rt.telemetry.tracer.Start(ctx, request.Method+" "+pathTemplate,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attributes...))
+		defer span.End()
+		request = request.WithContext(ctx)
+	}
+
+	start := time.Now()
+	response, err := rt.origin.RoundTrip(request)
+	if err != nil {
+		attributes = append(attributes, attribute.String("error.type", fmt.Sprintf("%T", err)))
+	} else {
+		attributes = append(attributes, attribute.Int("http.response.status_code", response.StatusCode))
+		if response.StatusCode >= http.StatusBadRequest {
+			attributes = append(attributes, attribute.String("error.type", strconv.Itoa(response.StatusCode)))
+		}
+	}
+	if rt.telemetry.requestDuration != nil {
+		rt.telemetry.requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attributes...))
+	}
+	if span != nil {
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case response.StatusCode >= http.StatusBadRequest:
+			span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+			span.SetStatus(codes.Error, response.Status)
+		default:
+			span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+		}
+	}
+
+	return response, err
+}
+
+// websocketConnected records connecting to the WebSocket of the hub. It is safe to call on a nil telemetry.
+func (t *telemetry) websocketConnected(address string, start time.Time, err error) {
+	if t == nil {
+		return
+	}
+	if t.tracer != nil {
+		_, span := t.tracer.Start(context.Background(), "websocket connect",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(start),
+			trace.WithAttributes(attribute.String("server.address", address)))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	if t.connections != nil && err == nil {
+		t.connections.Add(context.Background(), 1)
+	}
+}
+
+// websocketDisconnected records closing the WebSocket of the hub. It is safe to call on a nil telemetry.
+func (t *telemetry) websocketDisconnected(address string, err error) {
+	if t == nil {
+		return
+	}
+	if t.tracer != nil {
+		_, span := t.tracer.Start(context.Background(), "websocket disconnect",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("server.address", address)))
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+	if t.connections != nil {
+		t.connections.Add(context.Background(), -1)
+	}
+}
+
+// dispatchEvent wraps the dispatch of an event to the handlers in a span. It is safe to call on a nil telemetry.
+func (t *telemetry) dispatchEvent(event *Event, dispatch func()) {
+	if t == nil {
+		dispatch()
+		return
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("dirigera.event.type", event.Type),
+	}
+	if t.eventsReceived != nil {
+		t.eventsReceived.Add(context.Background(), 1, metric.WithAttributes(attributes...))
+	}
+	if t.tracer == nil {
+		dispatch()
+		return
+	}
+	_, span := t.tracer.Start(context.Background(), "event "+event.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(append(attributes,
+			attribute.String("dirigera.event.id", event.ID),
+			attribute.String("dirigera.device.id", event.Device.ID))...))
+	defer span.End()
+	dispatch()
+}
+
+// templatePath replaces IDs in the path by placeholders, e.g. /v1/devices/123 becomes /v1/devices/{id}.
+func templatePath(path string) string {
+	segments := strings.Split(path, "/")
+	for index := 1; index < len(segments); index++ {
+		if segments[index] == "" || segments[index] == "me" {
+			continue
+		}
+		for _, collection := range idCollections {
+			if segments[index-1] == collection {
+				segments[index] = "{id}"
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
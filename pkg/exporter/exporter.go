@@ -0,0 +1,251 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const namespace = "dirigera"
+
+var deviceLabels = []string{"device_id", "name", "room"}
+
+// attributeMetric maps a numeric or boolean device attribute to a gauge.
+type attributeMetric struct {
+	attribute   string
+	description *prometheus.Desc
+	valueType   prometheus.ValueType
+}
+
+var attributeMetrics = []attributeMetric{
+	newAttributeMetric("currentTemperature", "temperature_celsius", "Temperature measured by the device in degrees Celsius.", prometheus.GaugeValue),
+	newAttributeMetric("currentRH", "humidity_percent", "Relative humidity measured by the device in percent.", prometheus.GaugeValue),
+	newAttributeMetric("currentPM25", "pm25_micrograms_per_cubic_meter", "PM2.5 concentration measured by the device.", prometheus.GaugeValue),
+	newAttributeMetric("vocIndex", "voc_index", "VOC index measured by the device.", prometheus.GaugeValue),
+	newAttributeMetric("lightLevel", "light_level_percent", "Brightness of the light in percent.", prometheus.GaugeValue),
+	newAttributeMetric("isOn", "on", "Whether the device is switched on (1) or off (0).", prometheus.GaugeValue),
+	newAttributeMetric("blindsCurrentLevel", "blind_level_percent", "Current level of the blind in percent, 0 is open.", prometheus.GaugeValue),
+	newAttributeMetric("batteryPercentage", "battery_percent", "Battery level of the device in percent.", prometheus.GaugeValue),
+	newAttributeMetric("currentActivePower", "power_watts", "Active power consumed by the outlet in watts.", prometheus.GaugeValue),
+	newAttributeMetric("totalEnergyConsumed", "energy_consumed_kilowatt_hours_total", "Total energy consumed by the outlet in kilowatt hours.", prometheus.CounterValue),
+}
+
+var (
+	deviceInfoDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "info"),
+		"Information about the device, the value is always 1.",
+		append(deviceLabels, "type", "device_type", "model", "firmware_version"), nil)
+	deviceReachableDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "reachable"),
+		"Whether the device is reachable by the hub (1) or not (0).",
+		deviceLabels, nil)
+	deviceLastSeenDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "last_seen_age_seconds"),
+		"Seconds since the device was last seen by the hub.",
+		deviceLabels, nil)
+	hubInfoDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "hub", "info"),
+		"Information about the hub, the value is always 1.",
+		[]string{"hub_id", "name", "model", "firmware_version", "hardware_version"}, nil)
+	connectedDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "event_stream", "connected"),
+		"Whether the WebSocket connection for events is established (1) or not (0).",
+		nil, nil)
+	reconnectsDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "event_stream", "reconnects_total"),
+		"Number of times the WebSocket connection for events was established again.",
+		nil, nil)
+	eventsDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "event_stream", "events_received_total"),
+		"Number of events received from the hub by type.",
+		[]string{"type"}, nil)
+	lastEventDescription = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "event_stream", "last_event_timestamp_seconds"),
+		"Unix time of the last event received from the hub.",
+		nil, nil)
+)
+
+func newAttributeMetric(attribute, name, help string, valueType prometheus.ValueType) attributeMetric {
+	return attributeMetric{
+		attribute:   attribute,
+		description: prometheus.NewDesc(prometheus.BuildFQName(namespace, "device", name), help, deviceLabels, nil),
+		valueType:   valueType,
+	}
+}
+
+// Exporter is a prometheus.Collector providing the state of the devices and the hub. The state is read once
+// at start and updated from the events of the hub afterward, so scrapes do not cause requests to the hub.
+type Exporter struct {
+	dirigeraClient client.Client
+	logger         *slog.Logger
+	devices        *client.DeviceCache
+	mutex          sync.RWMutex
+	hub            *client.Hub
+	connected      bool
+	connections    int
+	events         map[string]float64
+	lastEvent      time.Time
+}
+
+// New creates a new Exporter for the hub. The logger may be nil.
+func New(dirigeraClient client.Client, logger *slog.Logger) *Exporter {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &Exporter{
+		dirigeraClient: dirigeraClient,
+		logger:         logger,
+		devices:        client.NewDeviceCache(dirigeraClient, logger),
+		events:         make(map[string]float64),
+	}
+}
+
+// HandleConnection tracks the health of the event loop. Pass it to client.WithConnectionHandler when creating
+// the client.
+func (e *Exporter) HandleConnection(connected bool, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.connected = connected
+	if connected {
+		e.connections++
+	} else if err != nil {
+		e.logger.Warn("event stream disconnected", "error", err)
+	}
+}
+
+// Run reads the devices and the hub and keeps them up to date from the events of the hub until the context
+// is cancelled.
+func (e *Exporter) Run(ctx context.Context) error {
+	if _, err := e.devices.Load(); err != nil {
+		return err
+	}
+	if err := e.readHub(); err != nil {
+		return err
+	}
+
+	return client.RunEvents(ctx, e.dirigeraClient, e.handleEvent, e.resync, e.logger)
+}
+
+// resync reads the devices and the hub again after the event connection was lost, changes may have been missed.
+func (e *Exporter) resync() {
+	e.devices.Reload()
+	if err := e.readHub(); err != nil {
+		e.logger.Warn("could not read hub", "error", err)
+	}
+}
+
+func (e *Exporter) readHub() error {
+	hub, err := e.dirigeraClient.GetHub()
+	if err != nil {
+		return fmt.Errorf("could not get hub: %w", err)
+	}
+	e.mutex.Lock()
+	e.hub = hub
+	e.mutex.Unlock()
+
+	return nil
+}
+
+func (e *Exporter) handleEvent(event client.Event) {
+	e.mutex.Lock()
+	e.events[event.Type]++
+	e.lastEvent = time.Now()
+	hubChanged := e.hub != nil && event.Device.ID == e.hub.ID
+	e.mutex.Unlock()
+
+	e.devices.Apply(event)
+	if hubChanged {
+		if err := e.readHub(); err != nil {
+			e.logger.Warn("could not read hub", "error", err)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- deviceInfoDescription
+	descriptions <- deviceReachableDescription
+	descriptions <- deviceLastSeenDescription
+	for _, metric := range attributeMetrics {
+		descriptions <- metric.description
+	}
+	descriptions <- hubInfoDescription
+	descriptions <- connectedDescription
+	descriptions <- reconnectsDescription
+	descriptions <- eventsDescription
+	descriptions <- lastEventDescription
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(metrics chan<- prometheus.Metric) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	now := time.Now()
+	for _, device := range e.devices.Devices() {
+		labels := []string{device.ID, deviceName(device), device.Room.Name}
+		metrics <- prometheus.MustNewConstMetric(deviceInfoDescription, prometheus.GaugeValue, 1,
+			append(labels, device.Type, device.DetailedType, stringAttribute(device, "model"), stringAttribute(device, "firmwareVersion"))...)
+		metrics <- prometheus.MustNewConstMetric(deviceReachableDescription, prometheus.GaugeValue, boolValue(device.IsReachable), labels...)
+		if !device.LastSeen.IsZero() {
+			metrics <- prometheus.MustNewConstMetric(deviceLastSeenDescription, prometheus.GaugeValue, now.Sub(device.LastSeen).Seconds(), labels...)
+		}
+		for _, metric := range attributeMetrics {
+			if value, found := numericAttribute(device, metric.attribute); found {
+				metrics <- prometheus.MustNewConstMetric(metric.description, metric.valueType, value, labels...)
+			}
+		}
+	}
+	if e.hub != nil {
+		metrics <- prometheus.MustNewConstMetric(hubInfoDescription, prometheus.GaugeValue, 1,
+			e.hub.ID, e.hub.Name, e.hub.Model, e.hub.FirmwareVersion, e.hub.HardwareVersion)
+	}
+
+	metrics <- prometheus.MustNewConstMetric(connectedDescription, prometheus.GaugeValue, boolValue(e.connected))
+	metrics <- prometheus.MustNewConstMetric(reconnectsDescription, prometheus.CounterValue, float64(max(e.connections-1, 0)))
+	for eventType, count := range e.events {
+		metrics <- prometheus.MustNewConstMetric(eventsDescription, prometheus.CounterValue, count, eventType)
+	}
+	if !e.lastEvent.IsZero() {
+		metrics <- prometheus.MustNewConstMetric(lastEventDescription, prometheus.GaugeValue, float64(e.lastEvent.Unix()))
+	}
+}
+
+func deviceName(device *client.Device) string {
+	if name := device.CustomName(); name != "" {
+		return name
+	}
+
+	return device.ID
+}
+
+func stringAttribute(device *client.Device, attribute string) string {
+	value, _ := device.Attributes[attribute].(string)
+	return value
+}
+
+func numericAttribute(device *client.Device, attribute string) (float64, bool) {
+	switch value := device.Attributes[attribute].(type) {
+	case float64:
+		return value, true
+	case bool:
+		return boolValue(value), true
+	default:
+		return 0, false
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+// handleEvent keeps the rooms of the devices up to date and forwards the event to the event streams.
+func (g *Gateway) handleEvent(event client.Event) {
+	// The room of a removed device is the last known one
+	var room client.Room
+	_, device := g.devices.Apply(event)
+	known := device != nil
+	if known {
+		room = device.Room
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for subscriber := range g.subscribers {
+		select {
+		case subscriber.events <- roomEvent{event: event, room: room, known: known}:
+		default:
+			g.options.Logger.Warn("event stream too slow, dropping event", "key", subscriber.key.Name, "event_type", event.Type)
+		}
+	}
+}
+
+// streamEvents sends the events of the hub as server-sent events until the client disconnects. The query parameter
+// type restricts the stream to the specified event types.
+func (g *Gateway) streamEvents(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	controller := http.NewResponseController(w)
+	subscriber := &subscriber{
+		key:    call.key,
+		types:  r.URL.Query()["type"],
+		events: make(chan roomEvent, subscriberBuffer),
+	}
+	g.mutex.Lock()
+	g.subscribers[subscriber] = struct{}{}
+	g.mutex.Unlock()
+	defer func() {
+		g.mutex.Lock()
+		delete(g.subscribers, subscriber)
+		g.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		return
+	}
+	// Event streams are not limited by the write timeout of the server
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case received := <-subscriber.events:
+			event := received.event
+			if len(subscriber.types) > 0 && !slices.Contains(subscriber.types, event.Type) {
+				continue
+			}
+			if len(call.key.Rooms) > 0 && (!received.known || !call.key.AllowsRoom(received.room)) {
+				continue
+			}
+			data, err := json.Marshal(newEventResource(event, received.room))
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package gateway
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const (
+	// BasePath is the path prefix of all endpoints of the API.
+	BasePath = "/api/v1"
+
+	heartbeatInterval = 30 * time.Second
+	subscriberBuffer  = 64
+	maxBodySize       = 64 << 10
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// Options configures the API keys and logging of the Gateway.
+type Options struct {
+	Keys []APIKey
+	// AuditLogger records every request with the key, the result and the changes made. Requests are not audited
+	// if nil.
+	AuditLogger *slog.Logger
+	Logger      *slog.Logger
+}
+
+// Gateway provides a simplified HTTP API of the hub for clients that authenticate with their own API keys, so the
+// access token of the hub is never shared.
+type Gateway struct {
+	dirigeraClient client.Client
+	options        Options
+	devices        *client.DeviceCache
+	mutex          sync.Mutex
+	subscribers    map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	key    *APIKey
+	types  []string
+	events chan roomEvent
+}
+
+// roomEvent is an event with the room of the device at the time the event was received.
+type roomEvent struct {
+	event client.Event
+	room  client.Room
+	known bool
+}
+
+// apiCall is the authenticated request passed to the handlers, which add details of changes to the audit record.
+type apiCall struct {
+	key   *APIKey
+	audit []any
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, call *apiCall)
+
+// New creates a Gateway for the hub. At least one API key is required.
+func New(dirigeraClient client.Client, options Options) (*Gateway, error) {
+	if len(options.Keys) == 0 {
+		return nil, fmt.Errorf("no API keys defined")
+	}
+	for index := range options.Keys {
+		if err := options.Keys[index].validate(); err != nil {
+			return nil, err
+		}
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+	if options.AuditLogger == nil {
+		options.AuditLogger = slog.New(slog.DiscardHandler)
+	}
+
+	return &Gateway{
+		dirigeraClient: dirigeraClient,
+		options:        options,
+		devices:        client.NewDeviceCache(dirigeraClient, options.Logger),
+		subscribers:    make(map[*subscriber]struct{}),
+	}, nil
+}
+
+// Handler returns the handler serving the API below BasePath.
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+BasePath+"/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(openAPISpec)
+	})
+	mux.Handle("GET "+BasePath+"/devices", g.authorize(ScopeRead, g.listDevices))
+	mux.Handle("GET "+BasePath+"/devices/{id}", g.authorize(ScopeRead, g.getDevice))
+	mux.Handle("PATCH "+BasePath+"/devices/{id}", g.authorize(ScopeControl, g.setDevice))
+	mux.Handle("GET "+BasePath+"/rooms", g.authorize(ScopeRead, g.listRooms))
+	mux.Handle("GET "+BasePath+"/rooms/{id}", g.authorize(ScopeRead, g.getRoom))
+	mux.Handle("GET "+BasePath+"/scenes", g.authorize(ScopeRead, g.listScenes))
+	mux.Handle("GET "+BasePath+"/scenes/{id}", g.authorize(ScopeRead, g.getScene))
+	mux.Handle("POST "+BasePath+"/scenes/{id}/trigger", g.authorize(ScopeScenes, g.triggerScene))
+	mux.Handle("GET "+BasePath+"/events", g.authorize(ScopeRead, g.streamEvents))
+	mux.HandleFunc(BasePath+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "unknown endpoint %s %s", r.Method, r.URL.Path)
+	})
+
+	return mux
+}
+
+// Run keeps track of the rooms of the devices and forwards the events of the hub to the event streams until the
+// context is cancelled.
+func (g *Gateway) Run(ctx context.Context) error {
+	if _, err := g.devices.Load(); err != nil {
+		return err
+	}
+
+	return client.RunEvents(ctx, g.dirigeraClient, g.handleEvent, g.devices.Reload, g.options.Logger)
+}
+
+// authorize authenticates the request by its API key, checks the scope and records the request in the audit log.
+func (g *Gateway) authorize(scope Scope, handler handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		call := &apiCall{}
+		defer func() {
+			attributes := []any{
+				"key", "",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote", r.RemoteAddr,
+				"status", recorder.status,
+				"duration", time.Since(start),
+			}
+			if call.key != nil {
+				attributes[1] = call.key.Name
+			}
+			g.options.AuditLogger.Info("request", append(attributes, call.audit...)...)
+		}()
+
+		secret := requestKey(r)
+		if secret == "" {
+			recorder.Header().Set("WWW-Authenticate", `Bearer realm="dirigera"`)
+			writeError(recorder, http.StatusUnauthorized, "API key missing")
+			return
+		}
+		call.key = findKey(g.options.Keys, secret)
+		if call.key == nil {
+			recorder.Header().Set("WWW-Authenticate", `Bearer realm="dirigera", error="invalid_token"`)
+			writeError(recorder, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		if !call.key.HasScope(scope) {
+			writeError(recorder, http.StatusForbidden, "API key %s has no scope %s", call.key.Name, scope)
+			return
+		}
+		handler(recorder, r, call)
+	})
+}
+
+// requestKey returns the API key from the header Authorization (Bearer) or X-API-Key.
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func (g *Gateway) listDevices(w http.ResponseWriter, _ *http.Request, call *apiCall) {
+	devices, err := g.dirigeraClient.ListDevices()
+	if err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	result := make([]deviceResource, 0, len(devices))
+	for _, device := range devices {
+		if call.key.AllowsRoom(device.Room) {
+			result = append(result, newDeviceResource(device))
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (g *Gateway) getDevice(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	device, found := g.findDevice(w, r.PathValue("id"), call.key)
+	if found {
+		writeJSON(w, http.StatusOK, newDeviceResource(device))
+	}
+}
+
+func (g *Gateway) setDevice(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	var change struct {
+		Attributes     map[string]interface{} `json:"attributes"`
+		TransitionTime int64                  `json:"transitionTime"`
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&change); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	if len(change.Attributes) == 0 {
+		writeError(w, http.StatusBadRequest, "no attributes specified")
+		return
+	}
+	if change.TransitionTime < 0 {
+		writeError(w, http.StatusBadRequest, "invalid transitionTime %d: must not be negative", change.TransitionTime)
+		return
+	}
+
+	device, found := g.findDevice(w, r.PathValue("id"), call.key)
+	if !found {
+		return
+	}
+	for name := range change.Attributes {
+		if !device.CanReceive(name) {
+			writeError(w, http.StatusBadRequest, "attribute %s can not be set, supported are %s", name, strings.Join(device.Capabilities.CanReceive, ", "))
+			return
+		}
+	}
+	call.audit = append(call.audit, "device", device.ID, "attributes", change.Attributes)
+	if err := g.dirigeraClient.SetDeviceAttributes(device.ID, change.Attributes, time.Duration(change.TransitionTime)*time.Millisecond); err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (g *Gateway) listRooms(w http.ResponseWriter, _ *http.Request, call *apiCall) {
+	rooms, err := g.dirigeraClient.ListRooms()
+	if err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	result := make([]roomResource, 0, len(rooms))
+	for _, room := range rooms {
+		if call.key.AllowsRoom(*room) {
+			result = append(result, roomResource{ID: room.ID, Name: room.Name})
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (g *Gateway) getRoom(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	rooms, err := g.dirigeraClient.ListRooms()
+	if err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	roomID := r.PathValue("id")
+	for _, room := range rooms {
+		if room.ID == roomID && call.key.AllowsRoom(*room) {
+			writeJSON(w, http.StatusOK, roomResource{ID: room.ID, Name: room.Name})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "room %s not found", roomID)
+}
+
+func (g *Gateway) listScenes(w http.ResponseWriter, _ *http.Request, call *apiCall) {
+	scenes, devices, err := g.readScenes()
+	if err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	result := make([]sceneResource, 0, len(scenes))
+	for _, scene := range scenes {
+		if allowsScene(call.key, scene, devices) {
+			result = append(result, newSceneResource(scene))
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (g *Gateway) getScene(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	scene, found := g.findScene(w, r.PathValue("id"), call.key)
+	if found {
+		writeJSON(w, http.StatusOK, newSceneResource(scene))
+	}
+}
+
+func (g *Gateway) triggerScene(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	scene, found := g.findScene(w, r.PathValue("id"), call.key)
+	if !found {
+		return
+	}
+	call.audit = append(call.audit, "scene", scene.ID, "scene_name", scene.Info.Name)
+	if err := g.dirigeraClient.TriggerScene(scene.ID); err != nil {
+		g.writeHubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// findDevice returns the device if it exists and the key allows its room, otherwise the error is written.
+// Devices in other rooms are reported as not found, so restricted keys can not detect them.
+func (g *Gateway) findDevice(w http.ResponseWriter, deviceID string, key *APIKey) (*client.Device, bool) {
+	devices, err := g.dirigeraClient.ListDevices()
+	if err != nil {
+		g.writeHubError(w, err)
+		return nil, false
+	}
+	for _, device := range devices {
+		if device.ID == deviceID && key.AllowsRoom(device.Room) {
+			return device, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "device %s not found", deviceID)
+
+	return nil, false
+}
+
+func (g *Gateway) findScene(w http.ResponseWriter, sceneID string, key *APIKey) (*client.Scene, bool) {
+	scenes, devices, err := g.readScenes()
+	if err != nil {
+		g.writeHubError(w, err)
+		return nil, false
+	}
+	for _, scene := range scenes {
+		if scene.ID == sceneID && allowsScene(key, scene, devices) {
+			return scene, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "scene %s not found", sceneID)
+
+	return nil, false
+}
+
+// readScenes returns the scenes and the devices by ID needed to check the rooms of the scenes.
+func (g *Gateway) readScenes() ([]*client.Scene, map[string]*client.Device, error) {
+	scenes, err := g.dirigeraClient.ListScenes()
+	if err != nil {
+		return nil, nil, err
+	}
+	devices, err := g.dirigeraClient.ListDevices()
+	if err != nil {
+		return nil, nil, err
+	}
+	devicesByID := make(map[string]*client.Device, len(devices))
+	for _, device := range devices {
+		devicesByID[device.ID] = device
+	}
+
+	return scenes, devicesByID, nil
+}
+
+// allowsScene returns true if the key allows the rooms of all devices changed by the scene. Keys restricted to
+// rooms can not use scenes without devices.
+func allowsScene(key *APIKey, scene *client.Scene, devices map[string]*client.Device) bool {
+	if len(key.Rooms) == 0 {
+		return true
+	}
+	hasDevices := false
+	for _, action := range scene.Actions {
+		if action.DeviceID == "" {
+			continue
+		}
+		device, found := devices[action.DeviceID]
+		if !found || !key.AllowsRoom(device.Room) {
+			return false
+		}
+		hasDevices = true
+	}
+
+	return hasDevices
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, errorResource{Error: fmt.Sprintf(format, args...)})
+}
+
+// writeHubError logs the failed call to the hub and reports it without details of the hub, e.g. its address.
+func (g *Gateway) writeHubError(w http.ResponseWriter, err error) {
+	g.options.Logger.Warn("request to the hub failed", "error", err)
+	var mismatch *client.FingerprintMismatchError
+	if errors.As(err, &mismatch) {
+		writeError(w, http.StatusBadGateway, "the hub presented an untrusted certificate")
+		return
+	}
+	writeError(w, http.StatusBadGateway, "request to the hub failed")
+}
+
+// statusRecorder keeps the status code for the audit log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
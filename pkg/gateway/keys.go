@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+// Scope grants access to a part of the API.
+type Scope string
+
+const (
+	// ScopeRead allows reading devices, rooms and scenes and receiving events.
+	ScopeRead Scope = "read"
+	// ScopeControl allows setting attributes of devices.
+	ScopeControl Scope = "control"
+	// ScopeScenes allows triggering scenes.
+	ScopeScenes Scope = "scenes"
+)
+
+var scopes = []Scope{ScopeRead, ScopeControl, ScopeScenes}
+
+const keyPrefix = "ikg_"
+
+// APIKey grants a client of the gateway access to the hub. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	Name   string   `yaml:"name"`
+	SHA256 string   `yaml:"sha256"`
+	Scopes []Scope  `yaml:"scopes,omitempty"` // Defaults to read
+	Rooms  []string `yaml:"rooms,omitempty"`  // IDs or names of the rooms the key is restricted to, all rooms if empty
+}
+
+// KeyFile is the content of the file with the API keys of the gateway.
+type KeyFile struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+// LoadKeys reads and validates the API keys from a YAML file.
+func LoadKeys(path string) ([]APIKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+	var keyFile KeyFile
+	if err := yaml.Unmarshal(content, &keyFile); err != nil {
+		return nil, fmt.Errorf("could not parse key file %s: %w", path, err)
+	}
+
+	var errs []error
+	names := make(map[string]bool, len(keyFile.Keys))
+	for index, key := range keyFile.Keys {
+		if err := key.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", index+1, err))
+		}
+		if names[key.Name] {
+			errs = append(errs, fmt.Errorf("key %d: name %s is used more than once", index+1, key.Name))
+		}
+		names[key.Name] = true
+	}
+	if len(keyFile.Keys) == 0 {
+		errs = append(errs, fmt.Errorf("no keys defined in %s", path))
+	}
+
+	return keyFile.Keys, errors.Join(errs...)
+}
+
+// GenerateKey returns a new random API key and the entry for the key file containing its hash.
+func GenerateKey(name string, keyScopes []Scope, rooms []string) (string, APIKey, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", APIKey{}, fmt.Errorf("could not generate key: %w", err)
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	key := APIKey{
+		Name:   name,
+		SHA256: hashKey(secret),
+		Scopes: keyScopes,
+		Rooms:  rooms,
+	}
+
+	return secret, key, key.validate()
+}
+
+func (k *APIKey) validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("name missing")
+	}
+	if hash, err := hex.DecodeString(k.SHA256); err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("invalid sha256 of key %s: must be 64 hexadecimal characters", k.Name)
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("invalid scope %s of key %s: must be %s, %s or %s", scope, k.Name, ScopeRead, ScopeControl, ScopeScenes)
+		}
+	}
+
+	return nil
+}
+
+// HasScope returns true if the key grants the scope. Keys without scopes are read-only.
+func (k *APIKey) HasScope(scope Scope) bool {
+	if len(k.Scopes) == 0 {
+		return scope == ScopeRead
+	}
+
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsRoom returns true if the key is not restricted to rooms or the room is one of its rooms.
+func (k *APIKey) AllowsRoom(room client.Room) bool {
+	if len(k.Rooms) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(k.Rooms, func(allowed string) bool {
+		return allowed == room.ID || (room.Name != "" && strings.EqualFold(allowed, room.Name))
+	})
+}
+
+func hashKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// findKey returns the key matching the secret or nil. All keys are compared in constant time.
+func findKey(keys []APIKey, secret string) *APIKey {
+	hash := hashKey(secret)
+	var found *APIKey
+	for index := range keys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(keys[index].SHA256))) == 1 {
+			found = &keys[index]
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+// The resources of the API are simplified views of the models of the hub, documented in openapi.yaml.
+
+type deviceResource struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	DeviceType string                 `json:"deviceType"`
+	Room       *roomResource          `json:"room,omitempty"`
+	Reachable  bool                   `json:"reachable"`
+	LastSeen   time.Time              `json:"lastSeen"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Settable   []string               `json:"settable"`
+}
+
+type roomResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type sceneResource struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Devices []string `json:"devices"`
+}
+
+type eventResource struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	DeviceID   string                 `json:"deviceId"`
+	Room       *roomResource          `json:"room,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type errorResource struct {
+	Error string `json:"error"`
+}
+
+func newDeviceResource(device *client.Device) deviceResource {
+	settable := device.Capabilities.CanReceive
+	if settable == nil {
+		settable = []string{}
+	}
+
+	return deviceResource{
+		ID:         device.ID,
+		Name:       device.CustomName(),
+		Type:       device.Type,
+		DeviceType: device.DetailedType,
+		Room:       newRoomResource(device.Room),
+		Reachable:  device.IsReachable,
+		LastSeen:   device.LastSeen,
+		Attributes: device.Attributes,
+		Settable:   settable,
+	}
+}
+
+func newRoomResource(room client.Room) *roomResource {
+	if room.ID == "" {
+		return nil
+	}
+
+	return &roomResource{ID: room.ID, Name: room.Name}
+}
+
+func newSceneResource(scene *client.Scene) sceneResource {
+	devices := []string{}
+	for _, action := range scene.Actions {
+		if action.DeviceID != "" {
+			devices = append(devices, action.DeviceID)
+		}
+	}
+
+	return sceneResource{
+		ID:      scene.ID,
+		Name:    scene.Info.Name,
+		Type:    scene.Type,
+		Devices: devices,
+	}
+}
+
+func newEventResource(event client.Event, room client.Room) eventResource {
+	return eventResource{
+		ID:         event.ID,
+		Type:       event.Type,
+		Time:       event.Time,
+		DeviceID:   event.Device.ID,
+		Room:       newRoomResource(room),
+		Attributes: event.Device.Attributes,
+	}
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const (
+	flushInterval = time.Second
+	pruneInterval = time.Hour
+)
+
+// Collector stores the events of the hub in a Store and removes records exceeding the retention. Events are
+// written in batches, so the store is not opened for every event.
+type Collector struct {
+	dirigeraClient client.Client
+	store          *Store
+	retention      Retention
+	logger         *slog.Logger
+	devices        *client.DeviceCache
+	mutex          sync.Mutex
+	pending        []Record
+}
+
+// NewCollector creates a Collector for the hub. The logger may be nil.
+func NewCollector(dirigeraClient client.Client, store *Store, retention Retention, logger *slog.Logger) *Collector {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &Collector{
+		dirigeraClient: dirigeraClient,
+		store:          store,
+		retention:      retention,
+		logger:         logger,
+		devices:        client.NewDeviceCache(dirigeraClient, logger),
+	}
+}
+
+// Run stores the events of the hub until the context is cancelled.
+func (c *Collector) Run(ctx context.Context) error {
+	if _, err := c.devices.Load(); err != nil {
+		return err
+	}
+	c.prune()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var maintenance sync.WaitGroup
+	maintenance.Go(func() {
+		flush := time.NewTicker(flushInterval)
+		defer flush.Stop()
+		prune := time.NewTicker(pruneInterval)
+		defer prune.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-flush.C:
+				if err := c.flush(); err != nil {
+					c.logger.Warn("could not store events", "error", err)
+				}
+			case <-prune.C:
+				c.prune()
+			}
+		}
+	})
+
+	err := client.RunEvents(ctx, c.dirigeraClient, c.handleEvent, c.devices.Reload, c.logger)
+	cancel()
+	maintenance.Wait()
+	if err != nil {
+		_ = c.flush()
+		return err
+	}
+
+	return c.flush()
+}
+
+func (c *Collector) handleEvent(event client.Event) {
+	// The name and room of a device are changed by its configuration
+	_, device := c.devices.Apply(event)
+	record := Record{
+		ID:         event.ID,
+		Type:       event.Type,
+		Time:       event.Time,
+		DeviceID:   event.Device.ID,
+		Attributes: event.Device.Attributes,
+	}
+	if record.ID == "" {
+		record.ID = uuid.NewString()
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if device != nil {
+		record.Device = device.CustomName()
+		record.DeviceType = device.Type
+		record.RoomID = device.Room.ID
+		record.Room = device.Room.Name
+	}
+
+	c.mutex.Lock()
+	c.pending = append(c.pending, record)
+	c.mutex.Unlock()
+}
+
+// flush writes the pending records. They are kept for the next flush if the store can not be written.
+func (c *Collector) flush() error {
+	c.mutex.Lock()
+	records := c.pending
+	c.pending = nil
+	c.mutex.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := c.store.Add(records...); err != nil {
+		c.mutex.Lock()
+		c.pending = append(records, c.pending...)
+		c.mutex.Unlock()
+		return err
+	}
+	c.logger.Debug("stored events", "count", len(records))
+
+	return nil
+}
+
+func (c *Collector) prune() {
+	if c.retention.MaxAge <= 0 && c.retention.MaxRecords <= 0 {
+		return
+	}
+	removed, err := c.store.Prune(c.retention, time.Now())
+	if err != nil {
+		c.logger.Warn("could not remove old events", "error", err)
+		return
+	}
+	if removed > 0 {
+		c.logger.Info("removed old events", "count", removed)
+	}
+}
@@ -0,0 +1,248 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var eventsBucket = []byte("events")
+
+// lockTimeout is the time to wait for another process using the store, e.g. a query while events are recorded.
+const lockTimeout = 10 * time.Second
+
+// Record is an event of the hub with the name and room the device had when the event was received.
+type Record struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	DeviceID   string                 `json:"deviceId"`
+	Device     string                 `json:"device,omitempty"`
+	DeviceType string                 `json:"deviceType,omitempty"`
+	RoomID     string                 `json:"roomId,omitempty"`
+	Room       string                 `json:"room,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Query selects records. All defined conditions must match.
+type Query struct {
+	Since time.Time // Inclusive, from the oldest record if zero
+	Until time.Time // Exclusive, to the newest record if zero
+	Types []string
+	// Devices and Rooms match by ID or name, names are compared case-insensitive.
+	Devices []string
+	Rooms   []string
+	// Attributes match records changing the attribute, given as name or name=value.
+	Attributes []string
+	// Limit returns only the newest records if greater than zero.
+	Limit int
+}
+
+// Retention defines which records are removed by Prune. Zero values do not limit the records.
+type Retention struct {
+	MaxAge     time.Duration
+	MaxRecords int
+}
+
+// Store keeps records in a bbolt database file. The database is only opened during an operation, so several
+// processes can use the store, e.g. to query records while events are recorded.
+type Store struct {
+	path     string
+	readOnly bool
+}
+
+// Open creates the store at the path if it does not exist.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+	err := store.update(func(bucket *bolt.Bucket) error {
+		return nil
+	})
+
+	return store, err
+}
+
+// OpenReadOnly opens an existing store for queries. It does not create or change the file, so it neither needs
+// write access nor waits for other readers.
+func OpenReadOnly(path string) (*Store, error) {
+	store := &Store{path: path, readOnly: true}
+	err := store.view(func(bucket *bolt.Bucket) error {
+		return nil
+	})
+
+	return store, err
+}
+
+// Add stores the records in one transaction.
+func (s *Store) Add(records ...Record) error {
+	return s.update(func(bucket *bolt.Bucket) error {
+		for _, record := range records {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("could not encode event %s: %w", record.ID, err)
+			}
+			if err := bucket.Put(recordKey(record), value); err != nil {
+				return fmt.Errorf("could not store event %s: %w", record.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns the matching records ordered by time.
+func (s *Store) Query(query Query) ([]Record, error) {
+	var records []Record
+	err := s.view(func(bucket *bolt.Bucket) error {
+		// Records are read from the newest, so a limit returns the latest records
+		cursor := bucket.Cursor()
+		key, value := cursor.Last()
+		if !query.Until.IsZero() {
+			untilKey := timeKey(query.Until)
+			key, value = cursor.Seek(untilKey)
+			if key == nil {
+				key, value = cursor.Last()
+			}
+			for key != nil && bytes.Compare(key, untilKey) >= 0 {
+				key, value = cursor.Prev()
+			}
+		}
+		var sinceKey []byte
+		if !query.Since.IsZero() {
+			sinceKey = timeKey(query.Since)
+		}
+
+		for ; key != nil; key, value = cursor.Prev() {
+			if sinceKey != nil && bytes.Compare(key, sinceKey) < 0 {
+				break
+			}
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("could not decode event: %w", err)
+			}
+			if !query.matches(record) {
+				continue
+			}
+			records = append(records, record)
+			if query.Limit > 0 && len(records) >= query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	slices.Reverse(records)
+
+	return records, err
+}
+
+// Prune removes the records exceeding the retention and returns the number of removed records.
+func (s *Store) Prune(retention Retention, now time.Time) (int, error) {
+	removed := 0
+	err := s.update(func(bucket *bolt.Bucket) error {
+		excess := 0
+		if retention.MaxRecords > 0 {
+			excess = bucket.Stats().KeyN - retention.MaxRecords
+		}
+		var oldestKey []byte
+		if retention.MaxAge > 0 {
+			oldestKey = timeKey(now.Add(-retention.MaxAge))
+		}
+
+		// Deleting with a cursor moves it to the next key
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.First() {
+			if removed >= excess && (oldestKey == nil || bytes.Compare(key, oldestKey) >= 0) {
+				break
+			}
+			if err := cursor.Delete(); err != nil {
+				return fmt.Errorf("could not remove event: %w", err)
+			}
+			removed++
+		}
+		return nil
+	})
+
+	return removed, err
+}
+
+func (s *Store) update(operation func(bucket *bolt.Bucket) error) error {
+	if s.readOnly {
+		return fmt.Errorf("history %s is opened read-only", s.path)
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return s.openError(err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(eventsBucket)
+		if err != nil {
+			return fmt.Errorf("could not create bucket: %w", err)
+		}
+		return operation(bucket)
+	})
+}
+
+func (s *Store) view(operation func(bucket *bolt.Bucket) error) error {
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return s.openError(err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		if bucket == nil {
+			return nil
+		}
+		return operation(bucket)
+	})
+}
+
+func (s *Store) openError(err error) error {
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("history %s is locked by another process", s.path)
+	}
+
+	return fmt.Errorf("could not open history %s: %w", s.path, err)
+}
+
+func (q *Query) matches(record Record) bool {
+	if len(q.Types) > 0 && !slices.Contains(q.Types, record.Type) {
+		return false
+	}
+	if len(q.Devices) > 0 && !slices.ContainsFunc(q.Devices, func(device string) bool {
+		return device == record.DeviceID || strings.EqualFold(device, record.Device)
+	}) {
+		return false
+	}
+	if len(q.Rooms) > 0 && !slices.ContainsFunc(q.Rooms, func(room string) bool {
+		return room == record.RoomID || strings.EqualFold(room, record.Room)
+	}) {
+		return false
+	}
+	if len(q.Attributes) > 0 && !slices.ContainsFunc(q.Attributes, func(attribute string) bool {
+		name, expected, hasValue := strings.Cut(attribute, "=")
+		value, changed := record.Attributes[name]
+		return changed && (!hasValue || strings.EqualFold(fmt.Sprint(value), expected))
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// recordKey orders records by time. The ID distinguishes records of the same time.
+func recordKey(record Record) []byte {
+	return append(timeKey(record.Time), record.ID...)
+}
+
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+// openTestStore creates a store with one record per minute, alternating between the front door and a kitchen lamp.
+func openTestStore(t *testing.T, count int) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	records := make([]Record, count)
+	for index := range records {
+		records[index] = Record{
+			ID:         fmt.Sprintf("event-%02d", index),
+			Type:       "deviceStateChanged",
+			Time:       testStart.Add(time.Duration(index) * time.Minute),
+			DeviceID:   "door-1",
+			Device:     "Front door",
+			RoomID:     "room-1",
+			Room:       "Hall",
+			Attributes: map[string]interface{}{"isOpen": index%4 == 0},
+		}
+		if index%2 == 1 {
+			records[index].DeviceID = "lamp-1"
+			records[index].Device = "Lamp"
+			records[index].RoomID = "room-2"
+			records[index].Room = "Kitchen"
+			records[index].Attributes = map[string]interface{}{"isOn": true}
+		}
+	}
+	if err := store.Add(records...); err != nil {
+		t.Fatalf("could not add records: %v", err)
+	}
+
+	return store
+}
+
+func recordIDs(records []Record) []string {
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+
+	return ids
+}
+
+func TestStoreQuery(t *testing.T) {
+	store := openTestStore(t, 10)
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"all", Query{}, []string{"event-00", "event-01", "event-02", "event-03", "event-04", "event-05", "event-06", "event-07", "event-08", "event-09"}},
+		{"limit returns the latest", Query{Limit: 3}, []string{"event-07", "event-08", "event-09"}},
+		{"since is inclusive", Query{Since: testStart.Add(7 * time.Minute)}, []string{"event-07", "event-08", "event-09"}},
+		{"until is exclusive", Query{Until: testStart.Add(2 * time.Minute)}, []string{"event-00", "event-01"}},
+		{"until after the newest", Query{Until: testStart.Add(time.Hour)}, []string{"event-00", "event-01", "event-02", "event-03", "event-04", "event-05", "event-06", "event-07", "event-08", "event-09"}},
+		{"until before the oldest", Query{Until: testStart.Add(-time.Hour)}, nil},
+		{"range", Query{Since: testStart.Add(3 * time.Minute), Until: testStart.Add(6 * time.Minute)}, []string{"event-03", "event-04", "event-05"}},
+		{"range with limit", Query{Since: testStart.Add(2 * time.Minute), Until: testStart.Add(8 * time.Minute), Limit: 2}, []string{"event-06", "event-07"}},
+		{"device by name", Query{Devices: []string{"front door"}, Limit: 2}, []string{"event-06", "event-08"}},
+		{"device by ID", Query{Devices: []string{"lamp-1"}, Until: testStart.Add(4 * time.Minute)}, []string{"event-01", "event-03"}},
+		{"room", Query{Rooms: []string{"kitchen"}, Since: testStart.Add(6 * time.Minute)}, []string{"event-07", "event-09"}},
+		{"attribute value", Query{Attributes: []string{"isOpen=true"}}, []string{"event-00", "event-04", "event-08"}},
+		{"attribute value with limit", Query{Attributes: []string{"isOpen=true"}, Limit: 1}, []string{"event-08"}},
+		{"type", Query{Types: []string{"deviceAdded"}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := store.Query(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ids := recordIDs(records); !slices.Equal(ids, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	now := testStart.Add(10 * time.Minute)
+	tests := []struct {
+		name      string
+		retention Retention
+		expected  []string
+	}{
+		{"no limits", Retention{}, []string{"event-00", "event-01", "event-02", "event-03", "event-04", "event-05", "event-06", "event-07", "event-08", "event-09"}},
+		{"max age", Retention{MaxAge: 4 * time.Minute}, []string{"event-06", "event-07", "event-08", "event-09"}},
+		{"max records", Retention{MaxRecords: 3}, []string{"event-07", "event-08", "event-09"}},
+		{"max age removes more", Retention{MaxAge: 2 * time.Minute, MaxRecords: 5}, []string{"event-08", "event-09"}},
+		{"max records removes more", Retention{MaxAge: 8 * time.Minute, MaxRecords: 1}, []string{"event-09"}},
+		{"max age keeps all", Retention{MaxAge: time.Hour}, []string{"event-00", "event-01", "event-02", "event-03", "event-04", "event-05", "event-06", "event-07", "event-08", "event-09"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := openTestStore(t, 10)
+			removed, err := store.Prune(test.retention, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if removed != 10-len(test.expected) {
+				t.Errorf("expected %d removed records, got %d", 10-len(test.expected), removed)
+			}
+			records, err := store.Query(Query{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ids := recordIDs(records); !slices.Equal(ids, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestStoreOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	if _, err := OpenReadOnly(path); err == nil {
+		t.Error("expected error for a missing store")
+	}
+	if _, err := Open(path); err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	store, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("could not open store read-only: %v", err)
+	}
+	if err := store.Add(Record{ID: "event", Time: testStart}); err == nil {
+		t.Error("expected error adding to a read-only store")
+	}
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var csvHeader = []string{"time", "measurement", "room", "device", "device_id", "type", "field", "value"}
+
+// CSVWriter writes points to one CSV file per day, named <prefix>-<yyyy-mm-dd>.csv. Every field is written as a
+// row of its own, so the columns do not depend on the types of the devices.
+type CSVWriter struct {
+	directory string
+	prefix    string
+	location  *time.Location
+	mutex     sync.Mutex
+	day       string
+	file      *os.File
+	writer    *csv.Writer
+}
+
+// NewCSVWriter creates a writer for the directory, which is created if it does not exist. Days are determined in
+// the location, e.g. time.Local.
+func NewCSVWriter(directory, prefix string, location *time.Location) (*CSVWriter, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory: %w", err)
+	}
+
+	return &CSVWriter{
+		directory: directory,
+		prefix:    prefix,
+		location:  location,
+	}, nil
+}
+
+func (w *CSVWriter) Write(points []Point) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, point := range points {
+		if err := w.rotate(point.Time.In(w.location).Format(time.DateOnly)); err != nil {
+			return err
+		}
+		timestamp := point.Time.In(w.location).Format(time.RFC3339Nano)
+		for _, field := range slices.Sorted(maps.Keys(point.Fields)) {
+			row := []string{
+				timestamp, point.Measurement, point.Tags["room"], point.Tags["device"], point.Tags["device_id"],
+				point.Tags["type"], field, formatValue(point.Fields[field]),
+			}
+			if err := w.writer.Write(row); err != nil {
+				return fmt.Errorf("could not write %s: %w", w.file.Name(), err)
+			}
+		}
+	}
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// rotate opens the file of the day if it is not already open. New files start with the header.
+func (w *CSVWriter) rotate(day string) error {
+	if day == w.day && w.file != nil {
+		return nil
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(w.directory, fmt.Sprintf("%s-%s.csv", w.prefix, day))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	w.day, w.file, w.writer = day, file, csv.NewWriter(file)
+	if info.Size() == 0 {
+		return w.writer.Write(csvHeader)
+	}
+
+	return nil
+}
+
+func (w *CSVWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	w.writer.Flush()
+	err := w.file.Close()
+	w.file, w.writer = nil, nil
+
+	return err
+}
+
+func (w *CSVWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.closeFile()
+}
+
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
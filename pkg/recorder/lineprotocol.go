@@ -0,0 +1,256 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxBufferedLines = 100000
+	httpWriteTimeout = 30 * time.Second
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// AppendLineProtocol appends the point in InfluxDB line protocol with nanosecond precision. Tags and fields are
+// sorted by key, as recommended for the best performance of InfluxDB.
+func AppendLineProtocol(buffer []byte, point Point) []byte {
+	buffer = append(buffer, measurementEscaper.Replace(point.Measurement)...)
+	for _, key := range slices.Sorted(maps.Keys(point.Tags)) {
+		if point.Tags[key] == "" {
+			continue
+		}
+		buffer = append(buffer, ',')
+		buffer = append(buffer, keyEscaper.Replace(key)...)
+		buffer = append(buffer, '=')
+		buffer = append(buffer, keyEscaper.Replace(point.Tags[key])...)
+	}
+	for index, key := range slices.Sorted(maps.Keys(point.Fields)) {
+		if index == 0 {
+			buffer = append(buffer, ' ')
+		} else {
+			buffer = append(buffer, ',')
+		}
+		buffer = append(buffer, keyEscaper.Replace(key)...)
+		buffer = append(buffer, '=')
+		switch value := point.Fields[key].(type) {
+		case float64:
+			buffer = strconv.AppendFloat(buffer, value, 'f', -1, 64)
+		case bool:
+			buffer = strconv.AppendBool(buffer, value)
+		case string:
+			buffer = append(buffer, '"')
+			buffer = append(buffer, stringEscaper.Replace(value)...)
+			buffer = append(buffer, '"')
+		default:
+			buffer = append(buffer, '"')
+			buffer = append(buffer, stringEscaper.Replace(fmt.Sprint(value))...)
+			buffer = append(buffer, '"')
+		}
+	}
+	buffer = append(buffer, ' ')
+	buffer = strconv.AppendInt(buffer, point.Time.UnixNano(), 10)
+
+	return append(buffer, '\n')
+}
+
+// LineProtocolWriter writes points as InfluxDB line protocol to a stream, e.g. stdout or a file.
+type LineProtocolWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	file   *os.File
+}
+
+// NewLineProtocolWriter creates a writer for the stream. The stream is not closed by the writer.
+func NewLineProtocolWriter(writer io.Writer) *LineProtocolWriter {
+	return &LineProtocolWriter{writer: writer}
+}
+
+// OpenLineProtocolFile creates a writer appending to the file, which is created if it does not exist and closed
+// with the writer.
+func OpenLineProtocolFile(path string) (*LineProtocolWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+
+	return &LineProtocolWriter{writer: file, file: file}, nil
+}
+
+func (w *LineProtocolWriter) Write(points []Point) error {
+	var buffer []byte
+	for _, point := range points {
+		buffer = AppendLineProtocol(buffer, point)
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := w.writer.Write(buffer)
+
+	return err
+}
+
+func (w *LineProtocolWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	return w.file.Close()
+}
+
+// InfluxHTTPWriter sends points in batches to an HTTP write endpoint of InfluxDB, e.g.
+// http://influxdb:8086/api/v2/write?org=home&bucket=climate&precision=ns. Points are kept and sent again with
+// the next batch if a write fails.
+type InfluxHTTPWriter struct {
+	url        string
+	token      string
+	batchSize  int
+	httpClient *http.Client
+	logger     *slog.Logger
+	mutex      sync.Mutex
+	lines      [][]byte
+	flush      chan struct{}
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// NewInfluxHTTPWriter creates a writer sending the points to the URL every flush interval or as soon as the batch
+// size is reached. The token is sent as "Authorization: Token <token>" if not empty. The logger may be nil.
+func NewInfluxHTTPWriter(url, token string, batchSize int, flushInterval time.Duration, logger *slog.Logger) *InfluxHTTPWriter {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	w := &InfluxHTTPWriter{
+		url:        url,
+		token:      token,
+		batchSize:  max(batchSize, 1),
+		httpClient: &http.Client{Timeout: httpWriteTimeout},
+		logger:     logger,
+		flush:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go w.loop(flushInterval)
+
+	return w
+}
+
+func (w *InfluxHTTPWriter) Write(points []Point) error {
+	w.mutex.Lock()
+	for _, point := range points {
+		w.lines = append(w.lines, AppendLineProtocol(nil, point))
+	}
+	w.trim()
+	full := len(w.lines) >= w.batchSize
+	w.mutex.Unlock()
+
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close sends the buffered points and stops the writer.
+func (w *InfluxHTTPWriter) Close() error {
+	close(w.stop)
+	<-w.stopped
+
+	return w.send()
+}
+
+func (w *InfluxHTTPWriter) loop(flushInterval time.Duration) {
+	defer close(w.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.flush:
+		}
+		if err := w.send(); err != nil {
+			w.logger.Warn("could not write points", "url", w.url, "error", err)
+		}
+	}
+}
+
+// send posts the buffered points in batches. A batch failing with a network error, 429 or 5xx is put back in front of
+// the buffer, so it is sent again with the next flush. A batch rejected with any other status is dropped, because
+// sending it again would fail the same way and block all newer points.
+func (w *InfluxHTTPWriter) send() error {
+	for {
+		w.mutex.Lock()
+		batch := w.lines[:min(len(w.lines), w.batchSize)]
+		w.lines = w.lines[len(batch):]
+		w.mutex.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if retry, err := w.post(bytes.Join(batch, nil)); err != nil {
+			if !retry {
+				w.logger.Warn("write endpoint rejected points, dropping them", "url", w.url, "dropped", len(batch), "error", err)
+				continue
+			}
+			w.mutex.Lock()
+			w.lines = slices.Concat(batch, w.lines)
+			w.trim()
+			w.mutex.Unlock()
+			return err
+		}
+	}
+}
+
+// trim drops the oldest points if the buffer is full. The mutex must be held.
+func (w *InfluxHTTPWriter) trim() {
+	if dropped := len(w.lines) - maxBufferedLines; dropped > 0 {
+		w.lines = w.lines[dropped:]
+		w.logger.Warn("write endpoint not reachable, dropping oldest points", "dropped", dropped)
+	}
+}
+
+// post sends the body to the write endpoint and returns whether a failed write should be retried.
+func (w *InfluxHTTPWriter) post(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpWriteTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error creating write call: %w", err)
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		request.Header.Set("Authorization", "Token "+w.token)
+	}
+	response, err := w.httpClient.Do(request)
+	if err != nil {
+		return true, fmt.Errorf("error writing points to %s: %w", w.url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("error writing points to %s: Received status code %d: %s", w.url, response.StatusCode, bytes.TrimSpace(message))
+	}
+
+	return false, nil
+}
@@ -0,0 +1,148 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRow is a row of ParquetWriter. Only the value column matching the type of the field is set.
+type parquetRow struct {
+	Time        int64    `parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Measurement string   `parquet:"name=measurement, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Room        string   `parquet:"name=room, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Device      string   `parquet:"name=device, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DeviceID    string   `parquet:"name=device_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Type        string   `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Field       string   `parquet:"name=field, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value       *float64 `parquet:"name=value, type=DOUBLE, repetitiontype=OPTIONAL"`
+	BoolValue   *bool    `parquet:"name=bool_value, type=BOOLEAN, repetitiontype=OPTIONAL"`
+	StringValue *string  `parquet:"name=string_value, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+// ParquetWriter writes points to one Parquet file per day, named <prefix>-<yyyy-mm-dd>.parquet, with one row per
+// field like CSVWriter. Numbers, booleans and strings are stored in the columns value, bool_value and string_value.
+// Parquet files can not be appended, so a file is only readable after it was closed at the end of the day or by
+// Close, and a restart on the same day continues in <prefix>-<yyyy-mm-dd>-2.parquet and so on.
+type ParquetWriter struct {
+	directory string
+	prefix    string
+	location  *time.Location
+	mutex     sync.Mutex
+	day       string
+	file      *os.File
+	writer    *writer.ParquetWriter
+}
+
+// NewParquetWriter creates a writer for the directory, which is created if it does not exist. Days are determined
+// in the location, e.g. time.Local.
+func NewParquetWriter(directory, prefix string, location *time.Location) (*ParquetWriter, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory: %w", err)
+	}
+
+	return &ParquetWriter{
+		directory: directory,
+		prefix:    prefix,
+		location:  location,
+	}, nil
+}
+
+func (w *ParquetWriter) Write(points []Point) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, point := range points {
+		if err := w.rotate(point.Time.In(w.location).Format(time.DateOnly)); err != nil {
+			return err
+		}
+		for _, field := range slices.Sorted(maps.Keys(point.Fields)) {
+			row := parquetRow{
+				Time:        point.Time.UnixMilli(),
+				Measurement: point.Measurement,
+				Room:        point.Tags["room"],
+				Device:      point.Tags["device"],
+				DeviceID:    point.Tags["device_id"],
+				Type:        point.Tags["type"],
+				Field:       field,
+			}
+			switch value := point.Fields[field].(type) {
+			case float64:
+				row.Value = &value
+			case bool:
+				row.BoolValue = &value
+			default:
+				text := formatValue(value)
+				row.StringValue = &text
+			}
+			if err := w.writer.Write(row); err != nil {
+				return fmt.Errorf("could not write %s: %w", w.file.Name(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rotate creates the file of the day if it is not already open. Files of the day written before are kept.
+func (w *ParquetWriter) rotate(day string) error {
+	if day == w.day && w.file != nil {
+		return nil
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	var file *os.File
+	for part := 1; file == nil; part++ {
+		name := fmt.Sprintf("%s-%s.parquet", w.prefix, day)
+		if part > 1 {
+			name = fmt.Sprintf("%s-%s-%d.parquet", w.prefix, day, part)
+		}
+		path := filepath.Join(w.directory, name)
+		var err error
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil && !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("could not create %s: %w", path, err)
+		}
+	}
+	parquetWriter, err := writer.NewParquetWriterFromWriter(file, new(parquetRow), 1)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not create %s: %w", file.Name(), err)
+	}
+	w.day, w.file, w.writer = day, file, parquetWriter
+
+	return nil
+}
+
+// closeFile writes the footer, which makes the file readable, and closes it.
+func (w *ParquetWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.writer.WriteStop()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = fmt.Errorf("could not write %s: %w", w.file.Name(), err)
+	}
+	w.file, w.writer = nil, nil
+
+	return err
+}
+
+func (w *ParquetWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.closeFile()
+}
@@ -0,0 +1,205 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+// DefaultMeasurement is the measurement of the points if not configured.
+const DefaultMeasurement = "dirigera"
+
+// DefaultFields maps the attributes of sensors, outlets, lights, blinds and air purifiers to field names.
+var DefaultFields = map[string]string{
+	"currentTemperature":  "temperature",
+	"currentRH":           "humidity",
+	"currentPM25":         "pm25",
+	"currentCO2":          "co2",
+	"vocIndex":            "voc_index",
+	"illuminance":         "illuminance",
+	"currentActivePower":  "power",
+	"currentAmps":         "current",
+	"currentVoltage":      "voltage",
+	"totalEnergyConsumed": "energy",
+	"batteryPercentage":   "battery",
+	"isOn":                "on",
+	"lightLevel":          "light_level",
+	"colorTemperature":    "color_temperature",
+	"blindsCurrentLevel":  "blind_level",
+	"fanMode":             "fan_mode",
+	"motorState":          "motor_state",
+	"isDetected":          "detected",
+	"isOpen":              "open",
+	"waterLeakDetected":   "water_leak",
+}
+
+// Point is a measurement of one device at a point in time, tagged with the room, name, ID and type of the device.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+// Writer stores points, e.g. as InfluxDB line protocol or in CSV or Parquet files.
+type Writer interface {
+	Write(points []Point) error
+	Close() error
+}
+
+// Options configures the points created by the Recorder.
+type Options struct {
+	Measurement string
+	// Fields maps attribute names to field names, DefaultFields if nil.
+	Fields map[string]string
+	// AllAttributes records every numeric and boolean attribute, using the mapped field name if defined and the
+	// attribute name otherwise.
+	AllAttributes bool
+	// Snapshot writes the current state of all devices when started and when the event connection is established
+	// again.
+	Snapshot bool
+	Logger   *slog.Logger
+}
+
+// Recorder turns deviceStateChanged events into points and writes them to a Writer.
+type Recorder struct {
+	dirigeraClient client.Client
+	writer         Writer
+	options        Options
+	devices        *client.DeviceCache
+}
+
+// New creates a Recorder writing the points of the hub to the writer.
+func New(dirigeraClient client.Client, writer Writer, options Options) *Recorder {
+	if options.Measurement == "" {
+		options.Measurement = DefaultMeasurement
+	}
+	if options.Fields == nil {
+		options.Fields = DefaultFields
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &Recorder{
+		dirigeraClient: dirigeraClient,
+		writer:         writer,
+		options:        options,
+		devices:        client.NewDeviceCache(dirigeraClient, options.Logger),
+	}
+}
+
+// Run records the events of the hub until the context is cancelled. The writer is not closed.
+func (r *Recorder) Run(ctx context.Context) error {
+	devices, err := r.devices.Load()
+	if err != nil {
+		return err
+	}
+	if err := r.writeSnapshot(devices); err != nil {
+		return err
+	}
+
+	return client.RunEvents(ctx, r.dirigeraClient, r.handleEvent, r.resync, r.options.Logger)
+}
+
+// resync reads the devices again after the event connection was lost and writes a snapshot, so changes missed in
+// the meantime are recorded.
+func (r *Recorder) resync() {
+	devices, err := r.devices.Load()
+	if err != nil {
+		r.options.Logger.Warn("could not read devices", "error", err)
+		return
+	}
+	if err := r.writeSnapshot(devices); err != nil {
+		r.options.Logger.Warn("could not resync", "error", err)
+	}
+}
+
+// writeSnapshot writes the current readings of the devices if enabled by the options.
+func (r *Recorder) writeSnapshot(devices []*client.Device) error {
+	if !r.options.Snapshot {
+		return nil
+	}
+	now := time.Now()
+	var snapshot []Point
+	for _, device := range devices {
+		if point, found := r.point(device, device.Attributes, now); found {
+			snapshot = append(snapshot, point)
+		}
+	}
+	if len(snapshot) > 0 {
+		if err := r.writer.Write(snapshot); err != nil {
+			return fmt.Errorf("could not write snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) handleEvent(event client.Event) {
+	// Names and rooms used as tags are changed by the configuration
+	_, device := r.devices.Apply(event)
+	if event.Type != "deviceStateChanged" || device == nil {
+		return
+	}
+	timestamp := event.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	point, found := r.point(device, event.Device.Attributes, timestamp)
+	if !found {
+		return
+	}
+	if err := r.writer.Write([]Point{point}); err != nil {
+		r.options.Logger.Warn("could not write point", "device", device.ID, "error", err)
+	}
+}
+
+// point creates the point of the device from the attributes and returns false if no attribute is recorded.
+func (r *Recorder) point(device *client.Device, attributes map[string]interface{}, timestamp time.Time) (Point, bool) {
+	fields := make(map[string]interface{})
+	for _, attribute := range slices.Sorted(maps.Keys(attributes)) {
+		name, mapped := r.options.Fields[attribute]
+		if !mapped && !r.options.AllAttributes {
+			continue
+		}
+		if !mapped {
+			name = attribute
+		}
+		switch value := attributes[attribute].(type) {
+		case float64, bool:
+			fields[name] = value
+		case string:
+			// Only mapped string attributes are recorded, e.g. the mode of an air purifier
+			if mapped {
+				fields[name] = value
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return Point{}, false
+	}
+
+	tags := map[string]string{
+		"device_id": device.ID,
+		"type":      device.Type,
+	}
+	if name := device.CustomName(); name != "" {
+		tags["device"] = name
+	}
+	if device.Room.Name != "" {
+		tags["room"] = device.Room.Name
+	}
+
+	return Point{
+		Measurement: r.options.Measurement,
+		Tags:        tags,
+		Fields:      fields,
+		Time:        timestamp,
+	}, true
+}
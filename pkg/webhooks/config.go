@@ -0,0 +1,178 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the payload with a built-in template.
+const (
+	FormatJSON    = "json"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+	FormatNtfy    = "ntfy"
+)
+
+// Formats are the formats with a built-in template.
+var Formats = []string{FormatJSON, FormatSlack, FormatDiscord, FormatNtfy}
+
+const (
+	defaultMaxAttempts    = 8
+	defaultInitialBackoff = 2 * time.Second
+	defaultMaxBackoff     = 10 * time.Minute
+	defaultTimeout        = 10 * time.Second
+)
+
+// Config is the content of the webhook file. Values of url, secret and headers may reference environment variables
+// like ${SLACK_URL}, so secrets do not have to be stored in the file.
+type Config struct {
+	// Queue is the directory pending deliveries are stored in, so they survive restarts. Deliveries are only kept
+	// in memory if empty.
+	Queue    string    `yaml:"queue"`
+	Webhooks []Webhook `yaml:"webhooks"`
+}
+
+// Webhook defines the events sent to a URL and the format of the payload.
+type Webhook struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Secret      string            `yaml:"secret,omitempty"` // Key of the HMAC-SHA256 signature, unsigned if empty
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Format      string            `yaml:"format,omitempty"`   // json, slack, discord or ntfy, defaults to json
+	Template    string            `yaml:"template,omitempty"` // Go template of the payload, replaces the format
+	ContentType string            `yaml:"content_type,omitempty"`
+	Filter      Filter            `yaml:"filter,omitempty"`
+	Retry       Retry             `yaml:"retry,omitempty"`
+	Timeout     time.Duration     `yaml:"timeout,omitempty"`
+
+	payloadTemplate *template.Template
+	expression      *template.Template
+}
+
+// Filter selects the events sent by a webhook. All defined conditions must match, an empty filter matches every
+// event.
+type Filter struct {
+	Types   []string `yaml:"types,omitempty"`
+	Devices []string `yaml:"devices,omitempty"` // IDs or names
+	Rooms   []string `yaml:"rooms,omitempty"`   // IDs or names
+	// Attributes matches events changing at least one of the attributes.
+	Attributes []string `yaml:"attributes,omitempty"`
+	// Expression is a Go template evaluated with the payload data, the event matches if the result is "true",
+	// e.g. {{ gt .State.currentTemperature 25.0 }}.
+	Expression string `yaml:"expression,omitempty"`
+}
+
+// Retry defines the exponential backoff of failed deliveries.
+type Retry struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// LoadConfig reads the webhook file, expands environment variables and validates the webhooks.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read webhook file: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("could not parse webhook file %s: %w", path, err)
+	}
+	if len(config.Webhooks) == 0 {
+		return nil, fmt.Errorf("no webhooks defined in %s", path)
+	}
+
+	var errs []error
+	names := make(map[string]bool, len(config.Webhooks))
+	for index := range config.Webhooks {
+		webhook := &config.Webhooks[index]
+		webhook.URL = os.ExpandEnv(webhook.URL)
+		webhook.Secret = os.ExpandEnv(webhook.Secret)
+		for name, value := range webhook.Headers {
+			webhook.Headers[name] = os.ExpandEnv(value)
+		}
+		if err := webhook.prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: %w", index+1, err))
+		}
+		if names[webhook.Name] {
+			errs = append(errs, fmt.Errorf("webhook %d: name %s is used more than once", index+1, webhook.Name))
+		}
+		names[webhook.Name] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// prepare validates the webhook, applies the defaults and parses the templates.
+func (w *Webhook) prepare() error {
+	if w.Name == "" {
+		return fmt.Errorf("name missing")
+	}
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("invalid url of webhook %s: must be an http or https URL", w.Name)
+	}
+	if w.Format == "" {
+		w.Format = FormatJSON
+	}
+	if !slices.Contains(Formats, w.Format) {
+		return fmt.Errorf("invalid format %s of webhook %s: must be json, slack, discord or ntfy", w.Format, w.Name)
+	}
+	if w.Retry.MaxAttempts <= 0 {
+		w.Retry.MaxAttempts = defaultMaxAttempts
+	}
+	if w.Retry.InitialBackoff <= 0 {
+		w.Retry.InitialBackoff = defaultInitialBackoff
+	}
+	if w.Retry.MaxBackoff <= 0 {
+		w.Retry.MaxBackoff = defaultMaxBackoff
+	}
+	if w.Timeout <= 0 {
+		w.Timeout = defaultTimeout
+	}
+
+	source := w.Template
+	if source == "" {
+		source = formatTemplates[w.Format]
+		if w.ContentType == "" {
+			w.ContentType = formatContentTypes[w.Format]
+		}
+	}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+	if w.payloadTemplate, err = template.New(w.Name).Funcs(TemplateFunctions).Option("missingkey=zero").Parse(source); err != nil {
+		return fmt.Errorf("invalid template of webhook %s: %w", w.Name, err)
+	}
+	if w.Filter.Expression != "" {
+		if w.expression, err = template.New(w.Name + " expression").Funcs(TemplateFunctions).Option("missingkey=zero").Parse(w.Filter.Expression); err != nil {
+			return fmt.Errorf("invalid expression of webhook %s: %w", w.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the next attempt after the specified number of failed attempts.
+func (r Retry) backoff(attempts int) time.Duration {
+	delay := r.InitialBackoff
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+
+	return delay
+}
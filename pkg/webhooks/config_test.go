@@ -0,0 +1,26 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	retry := Retry{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if delay := retry.backoff(test.attempts); delay != test.expected {
+			t.Errorf("expected backoff %v after %d attempts, got %v", test.expected, test.attempts, delay)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+const (
+	workers       = 4
+	maxRetryAfter = time.Hour
+)
+
+// UserAgent is sent with every request unless the headers define another.
+const UserAgent = "ikea-dirigera-client"
+
+// Headers sent with every delivery. The signature is the HMAC-SHA256 of "<timestamp>.<body>" with the secret of the
+// webhook, so receivers can verify the sender and reject replayed requests.
+const (
+	HeaderEvent     = "X-Dirigera-Event"
+	HeaderDelivery  = "X-Dirigera-Delivery"
+	HeaderTimestamp = "X-Dirigera-Timestamp"
+	HeaderSignature = "X-Dirigera-Signature"
+)
+
+// Dispatcher sends the events of the hub to the webhooks whose filter matches. Failed deliveries are retried with
+// exponential backoff and kept in the queue directory until they are delivered or the attempts are exhausted.
+type Dispatcher struct {
+	dirigeraClient client.Client
+	webhooks       []*Webhook
+	queue          *queue
+	logger         *slog.Logger
+	httpClient     *http.Client
+	devices        *client.DeviceCache
+	pending        chan *delivery
+	ctx            context.Context
+}
+
+// New creates a Dispatcher for the webhooks of the config. The logger may be nil.
+func New(dirigeraClient client.Client, config *Config, logger *slog.Logger) (*Dispatcher, error) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	deliveryQueue, err := newQueue(config.Queue)
+	if err != nil {
+		return nil, err
+	}
+	dispatcher := &Dispatcher{
+		dirigeraClient: dirigeraClient,
+		queue:          deliveryQueue,
+		logger:         logger,
+		httpClient:     &http.Client{},
+		devices:        client.NewDeviceCache(dirigeraClient, logger),
+		pending:        make(chan *delivery),
+	}
+	for index := range config.Webhooks {
+		dispatcher.webhooks = append(dispatcher.webhooks, &config.Webhooks[index])
+	}
+
+	return dispatcher, nil
+}
+
+// Run reads the devices, resumes the queued deliveries and dispatches the events of the hub until the context is
+// cancelled. Deliveries not sent until then stay in the queue.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	if _, err := d.devices.Load(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.ctx = ctx
+	var wait sync.WaitGroup
+	for range workers {
+		wait.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case next := <-d.pending:
+					d.deliver(ctx, next)
+				}
+			}
+		})
+	}
+	defer wait.Wait()
+
+	queued, err := d.queue.load()
+	if err != nil {
+		d.logger.Warn("could not load all queued deliveries", "error", err)
+	}
+	if len(queued) > 0 {
+		d.logger.Info("resuming queued deliveries", "count", len(queued))
+	}
+	for _, next := range queued {
+		d.schedule(next)
+	}
+
+	return client.RunEvents(ctx, d.dirigeraClient, d.handleEvent, d.devices.Reload, d.logger)
+}
+
+func (d *Dispatcher) handleEvent(event client.Event) {
+	_, device := d.devices.Apply(event)
+	payload := newPayload(event, device)
+	for _, webhook := range d.webhooks {
+		if !d.matches(webhook, event, device, payload) {
+			continue
+		}
+		payload.Webhook = webhook.Name
+		body, err := render(webhook.payloadTemplate, payload)
+		if err != nil {
+			d.logger.Warn("could not render payload", "webhook", webhook.Name, "event_type", event.Type, "error", err)
+			continue
+		}
+		next := &delivery{
+			ID:          uuid.NewString(),
+			Webhook:     webhook.Name,
+			EventType:   event.Type,
+			Body:        body,
+			CreatedAt:   time.Now(),
+			NextAttempt: time.Now(),
+		}
+		if err := d.queue.save(next); err != nil {
+			d.logger.Warn("could not queue delivery", "webhook", webhook.Name, "error", err)
+		}
+		d.schedule(next)
+	}
+}
+
+// matches returns true if all conditions of the filter of the webhook are met by the event.
+func (d *Dispatcher) matches(webhook *Webhook, event client.Event, device *client.Device, payload Payload) bool {
+	filter := webhook.Filter
+	if len(filter.Types) > 0 && !slices.Contains(filter.Types, event.Type) {
+		return false
+	}
+	if len(filter.Devices) > 0 && !slices.ContainsFunc(filter.Devices, func(selector string) bool {
+		return selector == event.Device.ID || (device != nil && strings.EqualFold(selector, device.CustomName()))
+	}) {
+		return false
+	}
+	if len(filter.Rooms) > 0 && !slices.ContainsFunc(filter.Rooms, func(selector string) bool {
+		return device != nil && (selector == device.Room.ID || strings.EqualFold(selector, device.Room.Name))
+	}) {
+		return false
+	}
+	if len(filter.Attributes) > 0 && !slices.ContainsFunc(filter.Attributes, func(attribute string) bool {
+		_, changed := event.Device.Attributes[attribute]
+		return changed
+	}) {
+		return false
+	}
+	if webhook.expression != nil {
+		result, err := render(webhook.expression, payload)
+		if err != nil {
+			// Expressions fail e.g. for attributes the device does not have
+			d.logger.Debug("expression failed", "webhook", webhook.Name, "device_id", event.Device.ID, "error", err)
+			return false
+		}
+		return strings.TrimSpace(string(result)) == "true"
+	}
+
+	return true
+}
+
+// schedule hands the delivery to the workers when its next attempt is due.
+func (d *Dispatcher) schedule(next *delivery) {
+	time.AfterFunc(max(time.Until(next.NextAttempt), 0), func() {
+		select {
+		case d.pending <- next:
+		case <-d.ctx.Done():
+		}
+	})
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, next *delivery) {
+	index := slices.IndexFunc(d.webhooks, func(webhook *Webhook) bool { return webhook.Name == next.Webhook })
+	if index < 0 {
+		d.logger.Warn("dropping delivery of unknown webhook", "webhook", next.Webhook, "delivery", next.ID)
+		_ = d.queue.fail(next)
+		return
+	}
+	webhook := d.webhooks[index]
+
+	retryAfter, err := d.send(ctx, webhook, next)
+	if ctx.Err() != nil {
+		// Stopped while sending, the delivery stays queued without counting the attempt
+		return
+	}
+	next.Attempts++
+	if err == nil {
+		d.logger.Info("delivered event", "webhook", webhook.Name, "event_type", next.EventType, "delivery", next.ID, "attempt", next.Attempts)
+		if err := d.queue.remove(next); err != nil {
+			d.logger.Warn("could not remove delivery from queue", "error", err)
+		}
+		return
+	}
+
+	next.LastError = err.Error()
+	permanent := retryAfter < 0
+	if permanent || next.Attempts >= webhook.Retry.MaxAttempts {
+		d.logger.Error("delivery failed, giving up", "webhook", webhook.Name, "event_type", next.EventType, "delivery", next.ID, "attempt", next.Attempts, "error", err)
+		if err := d.queue.fail(next); err != nil {
+			d.logger.Warn("could not move failed delivery", "error", err)
+		}
+		return
+	}
+	delay := max(webhook.Retry.backoff(next.Attempts), retryAfter)
+	next.NextAttempt = time.Now().Add(delay)
+	d.logger.Warn("delivery failed, retrying", "webhook", webhook.Name, "event_type", next.EventType, "delivery", next.ID, "attempt", next.Attempts, "retry_in", delay, "error", err)
+	if err := d.queue.save(next); err != nil {
+		d.logger.Warn("could not update queued delivery", "error", err)
+	}
+	d.schedule(next)
+}
+
+// NewRequest creates a POST request of the body to the URL with the header. The User-Agent is set to UserAgent unless
+// the header defines another.
+func NewRequest(ctx context.Context, url string, header http.Header, body []byte) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header = header.Clone()
+	if request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", UserAgent)
+	}
+
+	return request, nil
+}
+
+// Send sends the request with the HTTP client. The body of the response is discarded, so only its status and header
+// are available.
+func Send(httpClient *http.Client, request *http.Request) (*http.Response, error) {
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	return response, nil
+}
+
+// send posts the delivery once. On failure it returns the delay requested by the receiver with Retry-After, or a
+// negative delay if the request must not be retried.
+func (d *Dispatcher) send(ctx context.Context, webhook *Webhook, next *delivery) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhook.Timeout)
+	defer cancel()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set("Content-Type", webhook.ContentType)
+	header.Set(HeaderEvent, next.EventType)
+	header.Set(HeaderDelivery, next.ID)
+	header.Set(HeaderTimestamp, timestamp)
+	if webhook.Secret != "" {
+		header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, next.Body))
+	}
+	for name, value := range webhook.Headers {
+		header.Set(name, value)
+	}
+	request, err := NewRequest(ctx, webhook.URL, header, next.Body)
+	if err != nil {
+		return -1, err
+	}
+
+	response, err := Send(d.httpClient, request)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case response.StatusCode < http.StatusMultipleChoices:
+		return 0, nil
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode == http.StatusRequestTimeout,
+		response.StatusCode >= http.StatusInternalServerError:
+		return parseRetryAfter(response.Header.Get("Retry-After")), fmt.Errorf("received status code %d", response.StatusCode)
+	default:
+		return -1, fmt.Errorf("received status code %d", response.StatusCode)
+	}
+}
+
+// Sign returns the hexadecimal HMAC-SHA256 of "<timestamp>.<body>", sent as "sha256=<signature>" in the header
+// X-Dirigera-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return min(max(time.Until(date), 0), maxRetryAfter)
+	}
+
+	return 0
+}
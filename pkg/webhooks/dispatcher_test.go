@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		expected  string
+	}{
+		{"event", "my-secret", "1700000000", `{"type":"deviceStateChanged"}`, "0c660d25fe8921be8fa8ea7939c2e4860005ce27881e99df8a678a7f1ecb8dd3"},
+		{"empty body", "my-secret", "1700000000", "", "ccc51a59704c21fa4d3d7722fe68e90fe314832d58f8af04abf00f53b40e147b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if signature := Sign(test.secret, test.timestamp, []byte(test.body)); signature != test.expected {
+				t.Errorf("expected signature %s, got %s", test.expected, signature)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		minimum time.Duration
+		maximum time.Duration
+	}{
+		{"missing", "", 0, 0},
+		{"seconds", "120", 2 * time.Minute, 2 * time.Minute},
+		{"zero seconds", "0", 0, 0},
+		{"negative seconds", "-5", 0, 0},
+		{"seconds above maximum", "86400", maxRetryAfter, maxRetryAfter},
+		{"date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 55 * time.Second, time.Minute},
+		{"past date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"date above maximum", time.Now().Add(48 * time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, maxRetryAfter},
+		{"invalid", "soon", 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if delay := parseRetryAfter(test.value); delay < test.minimum || delay > test.maximum {
+				t.Errorf("expected delay between %v and %v for %q, got %v", test.minimum, test.maximum, test.value, delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/salex-org/ikea-dirigera-client/pkg/client"
+)
+
+var formatTemplates = map[string]string{
+	FormatJSON:    `{{ json . }}`,
+	FormatSlack:   `{"text":{{ json .Summary }}}`,
+	FormatDiscord: `{"content":{{ json .Summary }}}`,
+	FormatNtfy:    `{{ .Summary }}`,
+}
+
+var formatContentTypes = map[string]string{
+	FormatJSON:    "application/json",
+	FormatSlack:   "application/json",
+	FormatDiscord: "application/json",
+	FormatNtfy:    "text/plain; charset=utf-8",
+}
+
+// TemplateFunctions are the functions available in templates: json encodes a value as JSON.
+var TemplateFunctions = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// Payload is the data available in templates and expressions. The JSON format sends it as is.
+type Payload struct {
+	Webhook    string                 `json:"webhook"`
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	DeviceID   string                 `json:"deviceId"`
+	Device     string                 `json:"device"` // Name of the device, the ID if not named
+	DeviceType string                 `json:"deviceType"`
+	Room       string                 `json:"room"`
+	Attributes map[string]interface{} `json:"attributes"` // Attributes changed by the event
+	State      map[string]interface{} `json:"state"`      // All attributes of the device after the event
+	Summary    string                 `json:"summary"`    // Human-readable description of the event
+}
+
+func newPayload(event client.Event, device *client.Device) Payload {
+	payload := Payload{
+		ID:         event.ID,
+		Type:       event.Type,
+		Time:       event.Time,
+		DeviceID:   event.Device.ID,
+		Device:     event.Device.ID,
+		Attributes: event.Device.Attributes,
+		State:      event.Device.Attributes,
+	}
+	if payload.Attributes == nil {
+		payload.Attributes = map[string]interface{}{}
+	}
+	if device != nil {
+		if name := device.CustomName(); name != "" {
+			payload.Device = name
+		}
+		payload.DeviceType = device.Type
+		payload.Room = device.Room.Name
+		payload.State = device.Attributes
+	}
+	if payload.State == nil {
+		payload.State = map[string]interface{}{}
+	}
+	payload.Summary = summarize(payload)
+
+	return payload
+}
+
+// summarize describes the event in one line, e.g. "Kitchen/Ceiling: isOn=true, lightLevel=40".
+func summarize(payload Payload) string {
+	name := payload.Device
+	if payload.Room != "" {
+		name = payload.Room + "/" + name
+	}
+	switch payload.Type {
+	case "deviceAdded":
+		return name + " was added"
+	case "deviceRemoved":
+		return name + " was removed"
+	}
+
+	changes := make([]string, 0, len(payload.Attributes))
+	for _, attribute := range slices.Sorted(maps.Keys(payload.Attributes)) {
+		changes = append(changes, fmt.Sprintf("%s=%v", attribute, payload.Attributes[attribute]))
+	}
+	if len(changes) == 0 {
+		return name + ": " + payload.Type
+	}
+
+	return name + ": " + strings.Join(changes, ", ")
+}
+
+// EncodeMessage encodes a text message in the format and returns the content type and the body. The message is sent
+// as plain text to ntfy and as the text of a chat message to Slack and Discord. The format json sends an object
+// with the fields and the message.
+func EncodeMessage(format, message string, fields map[string]interface{}) (string, []byte, error) {
+	var body []byte
+	var err error
+	switch format {
+	case FormatNtfy:
+		body = []byte(message)
+	case FormatSlack:
+		body, err = json.Marshal(map[string]string{"text": message})
+	case FormatDiscord:
+		body, err = json.Marshal(map[string]string{"content": message})
+	case FormatJSON:
+		object := maps.Clone(fields)
+		if object == nil {
+			object = make(map[string]interface{})
+		}
+		object["message"] = message
+		body, err = json.Marshal(object)
+	default:
+		return "", nil, fmt.Errorf("invalid format %s", format)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return formatContentTypes[format], body, nil
+}
+
+func render(tmpl *template.Template, payload Payload) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, payload); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const failedDirectory = "failed"
+
+// delivery is a rendered payload waiting to be sent. The body is rendered once, so queued deliveries do not change
+// when the templates are changed.
+type delivery struct {
+	ID          string    `json:"id"`
+	Webhook     string    `json:"webhook"`
+	EventType   string    `json:"eventType"`
+	Body        []byte    `json:"body"`
+	Attempts    int       `json:"attempts"`
+	CreatedAt   time.Time `json:"createdAt"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// queue stores pending deliveries as one JSON file each. Deliveries that failed permanently are moved to the
+// subdirectory failed for inspection. All operations do nothing without a directory.
+type queue struct {
+	directory string
+}
+
+func newQueue(directory string) (*queue, error) {
+	if directory != "" {
+		if err := os.MkdirAll(filepath.Join(directory, failedDirectory), 0o700); err != nil {
+			return nil, fmt.Errorf("could not create queue directory: %w", err)
+		}
+	}
+
+	return &queue{directory: directory}, nil
+}
+
+func (q *queue) path(d *delivery) string {
+	return filepath.Join(q.directory, d.ID+".json")
+}
+
+// save writes the delivery atomically, so a crash never leaves a partial file.
+func (q *queue) save(d *delivery) error {
+	if q.directory == "" {
+		return nil
+	}
+	content, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("could not encode delivery %s: %w", d.ID, err)
+	}
+	temporary := q.path(d) + ".tmp"
+	if err := os.WriteFile(temporary, content, 0o600); err != nil {
+		return fmt.Errorf("could not write delivery %s: %w", d.ID, err)
+	}
+	if err := os.Rename(temporary, q.path(d)); err != nil {
+		return fmt.Errorf("could not write delivery %s: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+func (q *queue) remove(d *delivery) error {
+	if q.directory == "" {
+		return nil
+	}
+	if err := os.Remove(q.path(d)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove delivery %s: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+func (q *queue) fail(d *delivery) error {
+	if q.directory == "" {
+		return nil
+	}
+	if err := q.save(d); err != nil {
+		return err
+	}
+	if err := os.Rename(q.path(d), filepath.Join(q.directory, failedDirectory, d.ID+".json")); err != nil {
+		return fmt.Errorf("could not move failed delivery %s: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+// load returns the pending deliveries, e.g. from before a restart.
+func (q *queue) load() ([]*delivery, error) {
+	if q.directory == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(q.directory)
+	if err != nil {
+		return nil, fmt.Errorf("could not read queue directory: %w", err)
+	}
+
+	var deliveries []*delivery
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(q.directory, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var d delivery
+		if err := json.Unmarshal(content, &d); err != nil {
+			errs = append(errs, fmt.Errorf("invalid delivery %s: %w", entry.Name(), err))
+			continue
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, errors.Join(errs...)
+}